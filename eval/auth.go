@@ -0,0 +1,372 @@
+package eval
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andrewpillar/req/value"
+)
+
+// AuthCmd implements the auth family of commands for attaching credentials to
+// a request.
+var (
+	AuthCmd = &Command{
+		Name: "auth",
+		Argc: -1,
+		Func: family(authtab),
+	}
+
+	authtab = map[string]*Command{
+		"basic": {
+			Argc: 3,
+			Func: authBasic,
+		},
+		"bearer": {
+			Argc: 2,
+			Func: authBearer,
+		},
+		"digest": {
+			Argc: 3,
+			Func: authDigest,
+		},
+	}
+)
+
+// getCredentials returns the username and password from the given arguments,
+// along with the request to which they are being attached.
+func getCredentials(args []value.Value) (string, string, *value.Request, error) {
+	user, err := value.ToString(args[0])
+
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	pass, err := value.ToString(args[1])
+
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	req, err := value.ToRequest(args[2])
+
+	if err != nil {
+		return "", "", nil, err
+	}
+	return user.Value, pass.Value, req, nil
+}
+
+func authBasic(cmd string, args []value.Value) (value.Value, error) {
+	user, pass, req, err := getCredentials(args)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	req.SetBasicAuth(user, pass)
+	return req, nil
+}
+
+func authBearer(cmd string, args []value.Value) (value.Value, error) {
+	tok, err := value.ToString(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	req, err := value.ToRequest(args[1])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	req.Header.Set("Authorization", "Bearer "+tok.Value)
+	return req, nil
+}
+
+func authDigest(cmd string, args []value.Value) (value.Value, error) {
+	user, pass, req, err := getCredentials(args)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	req.Use(digestTransport(user, pass))
+	return req, nil
+}
+
+// digestAlgorithms is the table of supported algorithms for Digest
+// authentication, in order of preference.
+var digestAlgorithms = []struct {
+	name string
+	hash func() hash.Hash
+}{
+	{"SHA-256", sha256.New},
+	{"SHA-256-sess", sha256.New},
+	{"MD5", md5.New},
+	{"MD5-sess", md5.New},
+}
+
+// digestChallenge is a challenge sent by the server in the WWW-Authenticate
+// header for Digest authentication.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	hash      func() hash.Hash
+}
+
+// parseAuthParams parses the comma separated list of auth-params in a
+// WWW-Authenticate header, as described in RFC 7235 section 2.1.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+
+	for s != "" {
+		s = strings.TrimLeft(s, " \t,")
+
+		i := strings.IndexByte(s, '=')
+
+		if i < 0 {
+			break
+		}
+
+		key := strings.ToLower(strings.TrimSpace(s[:i]))
+		s = strings.TrimLeft(s[i+1:], " \t")
+
+		var val string
+
+		if strings.HasPrefix(s, "\"") {
+			var buf strings.Builder
+
+			i = 1
+
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				buf.WriteByte(s[i])
+			}
+
+			val = buf.String()
+
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			i = strings.IndexByte(s, ',')
+
+			if i < 0 {
+				i = len(s)
+			}
+
+			val = strings.TrimSpace(s[:i])
+			s = s[i:]
+		}
+		params[key] = val
+	}
+	return params
+}
+
+// getDigestChallenge returns the most preferable Digest challenge from the
+// WWW-Authenticate headers of the given response. Only challenges that
+// support the auth quality of protection are considered.
+func getDigestChallenge(resp *http.Response) (*digestChallenge, error) {
+	var chal *digestChallenge
+
+	rank := len(digestAlgorithms)
+
+	for _, hdr := range resp.Header.Values("WWW-Authenticate") {
+		scheme := hdr
+
+		if i := strings.IndexByte(hdr, ' '); i >= 0 {
+			scheme = hdr[:i]
+		}
+
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+
+		params := parseAuthParams(hdr[len(scheme):])
+
+		supported := false
+
+		for _, qop := range strings.Split(params["qop"], ",") {
+			if strings.TrimSpace(qop) == "auth" {
+				supported = true
+				break
+			}
+		}
+
+		if !supported {
+			continue
+		}
+
+		alg := params["algorithm"]
+
+		if alg == "" {
+			alg = "MD5"
+		}
+
+		for i, a := range digestAlgorithms {
+			if strings.EqualFold(a.name, alg) && i < rank {
+				rank = i
+				chal = &digestChallenge{
+					realm:     params["realm"],
+					nonce:     params["nonce"],
+					opaque:    params["opaque"],
+					algorithm: a.name,
+					hash:      a.hash,
+				}
+			}
+		}
+	}
+
+	if chal == nil {
+		return nil, errors.New("no supported digest challenge in response")
+	}
+	return chal, nil
+}
+
+func (c *digestChallenge) h(s string) string {
+	h := c.hash()
+	io.WriteString(h, s)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// authorize returns the value of the Authorization header for the given
+// request in response to the challenge, as described in RFC 7616.
+func (c *digestChallenge) authorize(r *http.Request, user, pass string) (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	cnonce := hex.EncodeToString(b)
+	nc := "00000001"
+	uri := r.URL.RequestURI()
+
+	ha1 := c.h(user + ":" + c.realm + ":" + pass)
+
+	if strings.HasSuffix(c.algorithm, "-sess") {
+		ha1 = c.h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+
+	ha2 := c.h(r.Method + ":" + uri)
+
+	resp := c.h(ha1 + ":" + c.nonce + ":" + nc + ":" + cnonce + ":auth:" + ha2)
+
+	var buf bytes.Buffer
+
+	buf.WriteString(`Digest username="` + user + `"`)
+	buf.WriteString(`, realm="` + c.realm + `"`)
+	buf.WriteString(`, nonce="` + c.nonce + `"`)
+	buf.WriteString(`, uri="` + uri + `"`)
+	buf.WriteString(`, algorithm=` + c.algorithm)
+	buf.WriteString(`, qop=auth, nc=` + nc)
+	buf.WriteString(`, cnonce="` + cnonce + `"`)
+	buf.WriteString(`, response="` + resp + `"`)
+
+	if c.opaque != "" {
+		buf.WriteString(`, opaque="` + c.opaque + `"`)
+	}
+	return buf.String(), nil
+}
+
+// bufferBody returns a copy of the given request whose body can be read
+// multiple times. If the request can already have its body read multiple
+// times then the original request is returned.
+func bufferBody(r *http.Request) (*http.Request, error) {
+	if r.Body == nil || r.Body == http.NoBody || r.GetBody != nil {
+		return r, nil
+	}
+
+	b, err := io.ReadAll(r.Body)
+
+	r.Body.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	r = r.Clone(r.Context())
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	return r, nil
+}
+
+// digestTransport returns middleware that will perform the challenge-response
+// for Digest authentication should the server respond with a 401.
+func digestTransport(user, pass string) func(http.RoundTripper) http.RoundTripper {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			r, err := bufferBody(r)
+
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := next.RoundTrip(r)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if resp.StatusCode != http.StatusUnauthorized {
+				return resp, nil
+			}
+
+			chal, err := getDigestChallenge(resp)
+
+			if err != nil {
+				// Not a challenge we can respond to, so leave it to the
+				// script to handle.
+				return resp, nil
+			}
+
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			auth, err := chal.authorize(r, user, pass)
+
+			if err != nil {
+				return nil, err
+			}
+
+			r2 := r.Clone(r.Context())
+			r2.Header.Set("Authorization", auth)
+
+			if r.GetBody != nil {
+				r2.Body, err = r.GetBody()
+
+				if err != nil {
+					return nil, err
+				}
+			}
+			return next.RoundTrip(r2)
+		})
+	}
+}
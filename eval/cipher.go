@@ -0,0 +1,393 @@
+package eval
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/andrewpillar/req/value"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// EncryptCmd implements the encrypt family of commands for encrypting data.
+// Each encrypt command has a respective decrypt command for decrypting the
+// data.
+var (
+	EncryptCmd = &Command{
+		Name: "encrypt",
+		Argc: -1,
+		Func: family(encrypttab),
+	}
+
+	encrypttab = map[string]*Command{
+		"aes-gcm": {
+			Argc: -1,
+			Func: encryptAEAD(newAESGCM),
+		},
+		"chacha20-poly1305": {
+			Argc: -1,
+			Func: encryptAEAD(chacha20poly1305.New),
+		},
+		"rsa-oaep": {
+			Argc: -1,
+			Func: encryptRSAOAEP,
+		},
+	}
+)
+
+// DecryptCmd implements the decrypt family of commands for decrypting data.
+var (
+	DecryptCmd = &Command{
+		Name: "decrypt",
+		Argc: -1,
+		Func: family(decrypttab),
+	}
+
+	decrypttab = map[string]*Command{
+		"aes-gcm": {
+			Argc: -1,
+			Func: decryptAEAD(newAESGCM),
+		},
+		"chacha20-poly1305": {
+			Argc: -1,
+			Func: decryptAEAD(chacha20poly1305.New),
+		},
+		"rsa-oaep": {
+			Argc: -1,
+			Func: decryptRSAOAEP,
+		},
+	}
+)
+
+// oaepHashes is the table of hash functions that can be used with RSA-OAEP.
+var oaepHashes = map[string]crypto.Hash{
+	"sha1":   crypto.SHA1,
+	"sha256": crypto.SHA256,
+	"sha384": crypto.SHA384,
+	"sha512": crypto.SHA512,
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type cipherOptions struct {
+	aad   []byte
+	nonce []byte
+	label []byte
+	hash  crypto.Hash
+}
+
+// getBytes returns the bytes of the string or stream in the given object under
+// the given key.
+func getBytes(obj *value.Object, key string) ([]byte, error) {
+	val, ok := obj.Pairs[key]
+
+	if !ok {
+		return nil, nil
+	}
+
+	b, err := readBytes(val)
+
+	if err != nil {
+		return nil, errors.New("key error " + key + ": " + err.Error())
+	}
+	return b, nil
+}
+
+// cipherArgs returns the key, options, and data from the given arguments. The
+// options are given as an optional object between the key and data.
+func cipherArgs(cmd string, args []value.Value) (value.Value, *cipherOptions, value.Value, error) {
+	if l := len(args); l < 2 || l > 3 {
+		err := errNotEnoughArgs
+
+		if l > 3 {
+			err = errTooManyArgs
+		}
+
+		return nil, nil, nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	opts := &cipherOptions{
+		hash: crypto.SHA256,
+	}
+
+	if len(args) == 2 {
+		return args[0], opts, args[1], nil
+	}
+
+	obj, err := value.ToObject(args[1])
+
+	if err != nil {
+		return nil, nil, nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	fields := []struct {
+		key string
+		p   *[]byte
+	}{
+		{"AAD", &opts.aad},
+		{"Nonce", &opts.nonce},
+		{"Label", &opts.label},
+	}
+
+	for _, fld := range fields {
+		*fld.p, err = getBytes(obj, fld.key)
+
+		if err != nil {
+			return nil, nil, nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+	}
+
+	hash, err := getString(obj, "Hash")
+
+	if err != nil {
+		return nil, nil, nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	if hash != "" {
+		h, ok := oaepHashes[hash]
+
+		if !ok {
+			return nil, nil, nil, &CommandError{
+				Cmd: cmd,
+				Err: errors.New("key error Hash: unsupported hash " + hash),
+			}
+		}
+		opts.hash = h
+	}
+	return args[0], opts, args[2], nil
+}
+
+func encryptAEAD(newAEAD func([]byte) (cipher.AEAD, error)) CommandFunc {
+	return func(cmd string, args []value.Value) (value.Value, error) {
+		keyval, opts, data, err := cipherArgs(cmd, args)
+
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := readBytes(keyval)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		aead, err := newAEAD(key)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		nonce := opts.nonce
+
+		if nonce == nil {
+			nonce = make([]byte, aead.NonceSize())
+
+			if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+				return nil, &CommandError{
+					Cmd: cmd,
+					Err: err,
+				}
+			}
+		}
+
+		if len(nonce) != aead.NonceSize() {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: errors.New("key error Nonce: expected nonce of " + strconv.Itoa(aead.NonceSize()) + " bytes"),
+			}
+		}
+
+		plaintext, err := readBytes(data)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		// The nonce is prepended to the ciphertext so it is available for
+		// decryption.
+		b := aead.Seal(append([]byte{}, nonce...), nonce, plaintext, opts.aad)
+
+		return value.NewStream(value.BufferStream(bytes.NewReader(b))), nil
+	}
+}
+
+func decryptAEAD(newAEAD func([]byte) (cipher.AEAD, error)) CommandFunc {
+	return func(cmd string, args []value.Value) (value.Value, error) {
+		keyval, opts, data, err := cipherArgs(cmd, args)
+
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := readBytes(keyval)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		aead, err := newAEAD(key)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		ciphertext, err := readBytes(data)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		if len(ciphertext) < aead.NonceSize() {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: errors.New("ciphertext too short"),
+			}
+		}
+
+		n := aead.NonceSize()
+
+		b, err := aead.Open(nil, ciphertext[:n], ciphertext[n:], opts.aad)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+		return value.NewStream(value.BufferStream(bytes.NewReader(b))), nil
+	}
+}
+
+func encryptRSAOAEP(cmd string, args []value.Value) (value.Value, error) {
+	keyval, opts, data, err := cipherArgs(cmd, args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parsePublicKey(keyval)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	pub, ok := key.(*rsa.PublicKey)
+
+	if !ok {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("cannot use " + keyType(key) + " key for rsa-oaep"),
+		}
+	}
+
+	plaintext, err := readBytes(data)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	b, err := rsa.EncryptOAEP(opts.hash.New(), rand.Reader, pub, plaintext, opts.label)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return value.NewStream(value.BufferStream(bytes.NewReader(b))), nil
+}
+
+func decryptRSAOAEP(cmd string, args []value.Value) (value.Value, error) {
+	keyval, opts, data, err := cipherArgs(cmd, args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parsePrivateKey(keyval)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	priv, ok := key.(*rsa.PrivateKey)
+
+	if !ok {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("cannot use " + keyType(key) + " key for rsa-oaep"),
+		}
+	}
+
+	ciphertext, err := readBytes(data)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	b, err := rsa.DecryptOAEP(opts.hash.New(), rand.Reader, priv, ciphertext, opts.label)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return value.NewStream(value.BufferStream(bytes.NewReader(b))), nil
+}
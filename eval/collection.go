@@ -0,0 +1,741 @@
+package eval
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andrewpillar/req/syntax"
+	"github.com/andrewpillar/req/value"
+)
+
+// LenCmd implements the len command for getting the length of an array,
+// object, or string.
+var LenCmd = &Command{
+	Name: "len",
+	Argc: 1,
+	Func: length,
+}
+
+// KeysCmd implements the keys command for getting the keys of an object.
+var KeysCmd = &Command{
+	Name: "keys",
+	Argc: 1,
+	Func: keys,
+}
+
+// ValuesCmd implements the values command for getting the values of an
+// object.
+var ValuesCmd = &Command{
+	Name: "values",
+	Argc: 1,
+	Func: values,
+}
+
+// AppendCmd implements the append command for appending values to an array.
+// The array is always the last argument, so this command can be chained.
+var AppendCmd = &Command{
+	Name: "append",
+	Argc: -1,
+	Func: appendItems,
+}
+
+// DelCmd implements the delete command for removing a key from an object, or
+// an index from an array.
+var DelCmd = &Command{
+	Name: "delete",
+	Argc: 2,
+	Func: deleteItem,
+}
+
+// SliceCmd implements the slice command for getting a portion of an array or
+// string.
+var SliceCmd = &Command{
+	Name: "slice",
+	Argc: -1,
+	Func: slice,
+}
+
+// MergeCmd implements the merge command for merging multiple objects, or
+// multiple arrays, into one.
+var MergeCmd = &Command{
+	Name: "merge",
+	Argc: -1,
+	Func: merge,
+}
+
+// FilterCmd implements the filter command for getting the items in an array
+// for which the given function returns true.
+var FilterCmd = &Command{
+	Name: "filter",
+	Argc: 2,
+	Func: filter,
+}
+
+// MapCmd implements the map command for calling the given function on each
+// item in an array, and returning an array of the results.
+var MapCmd = &Command{
+	Name: "map",
+	Argc: 2,
+	Func: mapItems,
+}
+
+// SortCmd implements the sort command for sorting the items in an array.
+var SortCmd = &Command{
+	Name: "sort",
+	Argc: -1,
+	Func: sortItems,
+}
+
+// UniqCmd implements the uniq command for removing duplicate items from an
+// array.
+var UniqCmd = &Command{
+	Name: "uniq",
+	Argc: -1,
+	Func: uniq,
+}
+
+// GroupCmd implements the group command for grouping the items in an array
+// into an object.
+var GroupCmd = &Command{
+	Name: "group",
+	Argc: 2,
+	Func: group,
+}
+
+// SumCmd implements the sum command for adding up the numbers in an array.
+var SumCmd = &Command{
+	Name: "sum",
+	Argc: -1,
+	Func: sum,
+}
+
+func length(cmd string, args []value.Value) (value.Value, error) {
+	var n int
+
+	switch v := args[0].(type) {
+	case *value.Array:
+		n = len(v.Items)
+	case *value.Object:
+		n = len(v.Order)
+	case value.String:
+		n = len([]rune(v.Value))
+	default:
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("cannot get length of " + value.Type(v)),
+		}
+	}
+	return value.Int{Value: int64(n)}, nil
+}
+
+func keys(cmd string, args []value.Value) (value.Value, error) {
+	obj, err := value.ToObject(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return obj.Keys(), nil
+}
+
+func values(cmd string, args []value.Value) (value.Value, error) {
+	obj, err := value.ToObject(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return obj.Values(), nil
+}
+
+// appendItems returns a new array with the given values appended to the array
+// in the last argument. The original array is left unmodified.
+func appendItems(cmd string, args []value.Value) (value.Value, error) {
+	if len(args) < 2 {
+		return nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: errNotEnoughArgs,
+		}
+	}
+
+	arr, err := value.ToArray(args[len(args)-1])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	arr = arr.Slice(0, len(arr.Items))
+
+	if err := arr.Append(args[:len(args)-1]...); err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return arr, nil
+}
+
+// deleteItem returns a new array or object with the given index or key
+// removed. Deleting an index or key that does not exist is not an error.
+func deleteItem(cmd string, args []value.Value) (value.Value, error) {
+	switch v := args[1].(type) {
+	case *value.Array:
+		i, err := value.ToInt(args[0])
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		if i.Value < 0 || i.Value > int64(len(v.Items)-1) {
+			return v.Slice(0, len(v.Items)), nil
+		}
+		return v.Delete(int(i.Value)), nil
+	case *value.Object:
+		key, err := value.ToString(args[0])
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		obj := v.Copy()
+		obj.Delete(key.Value)
+
+		return obj, nil
+	default:
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("cannot delete from " + value.Type(v)),
+		}
+	}
+}
+
+// sliceBounds returns the start and end of a slice for a value with the given
+// length. Indexes outside of the value are clamped to its bounds.
+func sliceBounds(start, end int64, l int) (int, int) {
+	n := int64(l)
+
+	if start < 0 {
+		start = 0
+	}
+	if end > n {
+		end = n
+	}
+	if start > end {
+		start = end
+	}
+	return int(start), int(end)
+}
+
+// slice returns the portion of the array or string between the given start
+// and end indexes. If no end is given, then the remainder of the array or
+// string is returned.
+func slice(cmd string, args []value.Value) (value.Value, error) {
+	if l := len(args); l < 2 || l > 3 {
+		err := errNotEnoughArgs
+
+		if l > 3 {
+			err = errTooManyArgs
+		}
+
+		return nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	start, err := value.ToInt(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	end := value.Int{Value: int64(^uint(0) >> 1)}
+
+	if len(args) > 2 {
+		end, err = value.ToInt(args[1])
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+	}
+
+	switch v := args[len(args)-1].(type) {
+	case *value.Array:
+		i, j := sliceBounds(start.Value, end.Value, len(v.Items))
+		return v.Slice(i, j), nil
+	case value.String:
+		r := []rune(v.Value)
+
+		i, j := sliceBounds(start.Value, end.Value, len(r))
+		return value.String{Value: string(r[i:j])}, nil
+	default:
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("cannot slice " + value.Type(v)),
+		}
+	}
+}
+
+// merge merges the given objects into a new object. Keys in later objects
+// will overwrite keys in earlier objects. If arrays are given, then a new
+// array of all the items in each array is returned.
+func merge(cmd string, args []value.Value) (value.Value, error) {
+	if len(args) < 2 {
+		return nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: errNotEnoughArgs,
+		}
+	}
+
+	switch v := args[0].(type) {
+	case *value.Array:
+		arr := v.Slice(0, len(v.Items))
+
+		for i, a := range args[1:] {
+			other, ok := a.(*value.Array)
+
+			if !ok {
+				return nil, &CommandError{
+					Cmd: cmd,
+					Err: errors.New("cannot merge " + value.Type(a) + " into array at argument " + strconv.Itoa(i+2)),
+				}
+			}
+
+			if err := arr.Append(other.Items...); err != nil {
+				return nil, &CommandError{
+					Cmd: cmd,
+					Err: err,
+				}
+			}
+		}
+		return arr, nil
+	case *value.Object:
+		obj := v.Copy()
+
+		for i, a := range args[1:] {
+			other, ok := a.(*value.Object)
+
+			if !ok {
+				return nil, &CommandError{
+					Cmd: cmd,
+					Err: errors.New("cannot merge " + value.Type(a) + " into object at argument " + strconv.Itoa(i+2)),
+				}
+			}
+
+			for _, k := range other.Order {
+				obj.Set(false, value.String{Value: k}, other.Pairs[k])
+			}
+		}
+		return obj, nil
+	default:
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("cannot merge " + value.Type(v)),
+		}
+	}
+}
+
+type itemFunc func(value.Value) (value.Value, error)
+
+// keyPath returns a function for getting the value at the given path of keys
+// from an item, each key being separated by a dot. If an array is encountered
+// in the path, then the key is used as the index into that array.
+func keyPath(path string) itemFunc {
+	keys := strings.Split(path, ".")
+
+	return func(v value.Value) (value.Value, error) {
+		for _, k := range keys {
+			if _, ok := v.(value.Zero); ok {
+				return v, nil
+			}
+
+			var key value.Value = value.String{Value: k}
+
+			if _, ok := v.(*value.Array); ok {
+				i, err := strconv.ParseInt(k, 10, 64)
+
+				if err != nil {
+					return nil, errors.New("cannot use " + strconv.Quote(k) + " as array index")
+				}
+				key = value.Int{Value: i}
+			}
+
+			index, err := value.ToIndex(v)
+
+			if err != nil {
+				return nil, err
+			}
+
+			v, err = index.Get(key)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	}
+}
+
+// getItemFunc returns the function to call on each item in an array. This
+// will either be a function literal, or a string containing a key path.
+func getItemFunc(v value.Value) (itemFunc, error) {
+	switch v := v.(type) {
+	case value.Func:
+		return v.Call, nil
+	case value.String:
+		return keyPath(v.Value), nil
+	default:
+		return nil, errors.New("cannot use " + value.Type(v) + " as func or key path")
+	}
+}
+
+// getItemArgs returns the function and array from the given arguments. If
+// only one argument is given, then the returned function returns each item
+// as is.
+func getItemArgs(cmd string, args []value.Value) (itemFunc, *value.Array, error) {
+	if l := len(args); l < 1 || l > 2 {
+		err := errNotEnoughArgs
+
+		if l > 2 {
+			err = errTooManyArgs
+		}
+
+		return nil, nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	fn := func(v value.Value) (value.Value, error) {
+		return v, nil
+	}
+
+	if len(args) > 1 {
+		var err error
+
+		fn, err = getItemFunc(args[0])
+
+		if err != nil {
+			return nil, nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+	}
+
+	arr, err := value.ToArray(args[len(args)-1])
+
+	if err != nil {
+		return nil, nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return fn, arr, nil
+}
+
+func filter(cmd string, args []value.Value) (value.Value, error) {
+	fn, arr, err := getItemArgs(cmd, args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]value.Value, 0, len(arr.Items))
+
+	for _, it := range arr.Items {
+		val, err := fn(it)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if value.Truthy(val) {
+			items = append(items, it)
+		}
+	}
+	return value.CopyArray(items), nil
+}
+
+func mapItems(cmd string, args []value.Value) (value.Value, error) {
+	fn, arr, err := getItemArgs(cmd, args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]value.Value, 0, len(arr.Items))
+
+	for _, it := range arr.Items {
+		val, err := fn(it)
+
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, val)
+	}
+
+	res, err := value.NewArray(items)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return res, nil
+}
+
+// sortItems sorts the items in the array in ascending order, unless desc is
+// given. Items can be sorted by the result of a function, or by a key path.
+// The sort is stable, so items that are equal keep their original order.
+func sortItems(cmd string, args []value.Value) (value.Value, error) {
+	if l := len(args); l < 1 || l > 3 {
+		err := errNotEnoughArgs
+
+		if l > 3 {
+			err = errTooManyArgs
+		}
+
+		return nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	desc := false
+
+	if l := len(args); l > 1 {
+		if name, ok := args[l-2].(value.Name); ok {
+			switch name.Value {
+			case "asc":
+			case "desc":
+				desc = true
+			default:
+				return nil, &CommandError{
+					Cmd: cmd,
+					Err: errors.New("unexpected sort order " + name.Value),
+				}
+			}
+			args = append(args[:l-2:l-2], args[l-1])
+		}
+	}
+
+	fn, arr, err := getItemArgs(cmd, args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]value.Value, 0, len(arr.Items))
+
+	for _, it := range arr.Items {
+		key, err := fn(it)
+
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	ind := make([]int, len(arr.Items))
+
+	for i := range ind {
+		ind[i] = i
+	}
+
+	op := syntax.LtOp
+
+	if desc {
+		op = syntax.GtOp
+	}
+
+	var cmperr error
+
+	sort.SliceStable(ind, func(i, j int) bool {
+		if cmperr != nil {
+			return false
+		}
+
+		a, b := promoteNumbers(keys[ind[i]], keys[ind[j]])
+
+		val, err := value.Compare(a, op, b)
+
+		if err != nil {
+			cmperr = err
+			return false
+		}
+		return value.Truthy(val)
+	})
+
+	if cmperr != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: cmperr,
+		}
+	}
+
+	items := make([]value.Value, 0, len(ind))
+
+	for _, i := range ind {
+		items = append(items, arr.Items[i])
+	}
+	return value.CopyArray(items), nil
+}
+
+// promoteNumbers returns the given values with an int converted to a float if
+// the other value is a float, so the two can be compared.
+func promoteNumbers(a, b value.Value) (value.Value, value.Value) {
+	if i, ok := a.(value.Int); ok {
+		if _, ok := b.(value.Float); ok {
+			a = value.Float{Value: float64(i.Value)}
+		}
+	}
+	if i, ok := b.(value.Int); ok {
+		if _, ok := a.(value.Float); ok {
+			b = value.Float{Value: float64(i.Value)}
+		}
+	}
+	return a, b
+}
+
+// uniq removes the duplicate items from the array, keeping the first of each.
+// If a function or key path is given, then items are considered duplicates
+// if they have the same result.
+func uniq(cmd string, args []value.Value) (value.Value, error) {
+	fn, arr, err := getItemArgs(cmd, args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{})
+	items := make([]value.Value, 0, len(arr.Items))
+
+	for _, it := range arr.Items {
+		key, err := fn(it)
+
+		if err != nil {
+			return nil, err
+		}
+
+		s := key.String()
+
+		if _, ok := seen[s]; ok {
+			continue
+		}
+
+		seen[s] = struct{}{}
+		items = append(items, it)
+	}
+	return value.CopyArray(items), nil
+}
+
+// group groups the items in the array into an object, keyed by the result of
+// the given function or key path. The keys are in the order in which they
+// were first seen.
+func group(cmd string, args []value.Value) (value.Value, error) {
+	fn, arr, err := getItemArgs(cmd, args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	order := make([]string, 0)
+	groups := make(map[string][]value.Value)
+
+	for _, it := range arr.Items {
+		key, err := fn(it)
+
+		if err != nil {
+			return nil, err
+		}
+
+		s := key.Sprint()
+
+		if _, ok := groups[s]; !ok {
+			order = append(order, s)
+		}
+		groups[s] = append(groups[s], it)
+	}
+
+	obj := &value.Object{
+		Order: order,
+		Pairs: make(map[string]value.Value),
+	}
+
+	for k, items := range groups {
+		obj.Pairs[k] = value.CopyArray(items)
+	}
+	return obj, nil
+}
+
+// sum adds up the numbers in the array, or the numbers returned from the given
+// function or key path. If any of the numbers are floats, then a float is
+// returned, otherwise an int is returned. Zero values are skipped.
+func sum(cmd string, args []value.Value) (value.Value, error) {
+	fn, arr, err := getItemArgs(cmd, args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		i     int64
+		f     float64
+		float bool
+	)
+
+	for _, it := range arr.Items {
+		val, err := fn(it)
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch v := val.(type) {
+		case value.Int:
+			i += v.Value
+		case value.Float:
+			f += v.Value
+			float = true
+		case value.Zero:
+		default:
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: errors.New("cannot sum " + value.Type(v)),
+			}
+		}
+	}
+
+	if float {
+		return value.Float{Value: f + float64(i)}, nil
+	}
+	return value.Int{Value: i}, nil
+}
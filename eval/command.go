@@ -4,8 +4,9 @@ import (
 	"bytes"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/base32"
 	"encoding/base64"
-	"encoding/json"
+	"encoding/hex"
 	"errors"
 	"io"
 	"mime"
@@ -17,6 +18,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/andrewpillar/req/value"
@@ -47,6 +49,8 @@ var (
 	errTooManyArgs   = errors.New("too many arguments")
 )
 
+func (e *CommandError) Unwrap() error { return e.Err }
+
 func (e *CommandError) Error() string {
 	if e.Op != "" {
 		return "invalid " + e.Op + " to " + e.Cmd + ": " + e.Err.Error()
@@ -82,6 +86,72 @@ func (c Command) invoke(args []value.Value) (value.Value, error) {
 	return c.Func(c.Name, args)
 }
 
+// family returns a CommandFunc for a family of commands. The first argument
+// given to the returned function is used as the name of the sub-command to
+// invoke from the given table, the remaining arguments are passed to that
+// sub-command.
+func family(tab map[string]*Command) CommandFunc {
+	return func(cmd string, args []value.Value) (value.Value, error) {
+		if len(args) < 1 {
+			return nil, &CommandError{
+				Op:  "call",
+				Cmd: cmd,
+				Err: errNotEnoughArgs,
+			}
+		}
+
+		name, err := value.ToName(args[0])
+
+		if err != nil {
+			return nil, err
+		}
+
+		subcmd, ok := tab[name.Value]
+
+		if !ok {
+			return nil, errors.New("undefined command: " + cmd + " " + name.Value)
+		}
+
+		subcmd.Name = cmd + " " + name.Value
+
+		return subcmd.invoke(args[1:])
+	}
+}
+
+// getString returns the string in the given object under the given key. If
+// the key does not exist in the object then an empty string is returned.
+func getString(obj *value.Object, key string) (string, error) {
+	val, ok := obj.Pairs[key]
+
+	if !ok {
+		return "", nil
+	}
+
+	str, err := value.ToString(val)
+
+	if err != nil {
+		return "", errors.New("key error " + key + ": " + err.Error())
+	}
+	return str.Value, nil
+}
+
+// getBool returns the bool in the given object under the given key. If the
+// key does not exist in the object then false is returned.
+func getBool(obj *value.Object, key string) (bool, error) {
+	val, ok := obj.Pairs[key]
+
+	if !ok {
+		return false, nil
+	}
+
+	b, err := value.ToBool(val)
+
+	if err != nil {
+		return false, errors.New("key error " + key + ": " + err.Error())
+	}
+	return b.Value, nil
+}
+
 var CookieCmd = &Command{
 	Name: "cookie",
 	Argc: 1,
@@ -568,6 +638,18 @@ func request(cmd string, args []value.Value) (value.Value, error) {
 		return nil, err
 	}
 
+	// Allow for the request body to be read again should the request need to
+	// be sent multiple times, as would be the case with redirects or
+	// authentication challenges.
+	if s, ok := body.(value.Stream); ok {
+		req.GetBody = func() (io.ReadCloser, error) {
+			if _, err := s.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return io.NopCloser(s), nil
+		}
+	}
+
 	if obj != nil {
 		for key, val := range obj.Pairs {
 			if key == "Cookie" {
@@ -751,24 +833,60 @@ func tlsfn(cmd string, args []value.Value) (value.Value, error) {
 		}
 	}
 
-	tlscfg := &tls.Config{
+	req.Transport = newTLSTransport(&tls.Config{
 		RootCAs:      rootCAs,
 		Certificates: certs,
-	}
+	})
+	return req, nil
+}
+
+// tlsTransport is the transport for requests configured via the tls command.
+// The TLS config is kept so a transport for HTTP/2 can be made from it for
+// gRPC calls.
+type tlsTransport struct {
+	*http.Transport
+
+	config *tls.Config
 
-	req.Transport = &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialTLS: func(network, addr string) (net.Conn, error) {
-			return tls.Dial(network, addr, tlscfg)
+	once sync.Once
+	h2   *tlsTransport
+}
+
+func newTLSTransport(cfg *tls.Config) *tlsTransport {
+	return &tlsTransport{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialTLS: func(network, addr string) (net.Conn, error) {
+				return tls.Dial(network, addr, cfg)
+			},
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
 		},
-		ForceAttemptHTTP2:     true,
-		MaxIdleConns:          100,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
+		config: cfg,
 	}
+}
 
-	return req, nil
+// http2 returns a copy of the transport that negotiates HTTP/2. The copy is
+// made once, so its connections are reused across calls.
+func (t *tlsTransport) http2() *tlsTransport {
+	t.once.Do(func() {
+		cfg := t.config.Clone()
+		cfg.NextProtos = []string{"h2"}
+
+		t.h2 = newTLSTransport(cfg)
+	})
+	return t.h2
+}
+
+// roundTripperFunc allows for an ordinary function to be used as an
+// http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
 }
 
 // SendCmd implements the send command for sending a request.
@@ -788,8 +906,21 @@ func send(cmd string, args []value.Value) (value.Value, error) {
 		}
 	}
 
+	// The request may have already been sent, so make sure the body is read
+	// from the start again.
+	if req.GetBody != nil {
+		req.Body, err = req.GetBody()
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+	}
+
 	cli := http.Client{
-		Transport: req.Transport,
+		Transport: req.RoundTripper(),
 	}
 
 	resp, err := cli.Do(req.Request)
@@ -806,6 +937,28 @@ func send(cmd string, args []value.Value) (value.Value, error) {
 	}, nil
 }
 
+// SleepCmd implements the sleep command for pausing script execution for the
+// given duration.
+var SleepCmd = &Command{
+	Name: "sleep",
+	Argc: 1,
+	Func: sleep,
+}
+
+func sleep(cmd string, args []value.Value) (value.Value, error) {
+	d, err := value.ToDuration(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	time.Sleep(d.Value)
+	return nil, nil
+}
+
 // SniffCmd implements the sniff command for inspecting the content type of a
 // stream.
 var SniffCmd = &Command{
@@ -851,79 +1004,216 @@ func sniff(cmd string, args []value.Value) (value.Value, error) {
 var (
 	EncodeCmd = &Command{
 		Name: "encode",
-		Argc: 2,
-		Func: encode,
+		Argc: -1,
+		Func: family(encodetab),
 	}
 
 	encodetab = map[string]*Command{
+		"base32": {
+			Argc: -1,
+			Func: encodeText(base32Encoder(base32.StdEncoding)),
+		},
 		"base64": {
+			Argc: -1,
+			Func: encodeText(base64Encoder(base64.StdEncoding)),
+		},
+		"base64url": {
+			Argc: -1,
+			Func: encodeText(base64Encoder(base64.URLEncoding)),
+		},
+		"br": {
+			Argc: 1,
+			Func: encodeCompressed("br"),
+		},
+		"cbor": {
+			Argc: 1,
+			Func: encodeBinary(value.EncodeCBOR),
+		},
+		"csv": {
+			Argc: -1,
+			Func: encodeCsv(','),
+		},
+		"deflate": {
 			Argc: 1,
-			Func: encodeBase64,
+			Func: encodeCompressed("deflate"),
 		},
 		"form-data": {
 			Argc: 1,
 			Func: encodeFormData(""),
 		},
-		"json": {
+		"gzip": {
 			Argc: 1,
+			Func: encodeCompressed("gzip"),
+		},
+		"hex": {
+			Argc: -1,
+			Func: encodeText(hexEncoder),
+		},
+		"json": {
+			Argc: -1,
 			Func: encodeJson,
 		},
+		"jwt": {
+			Argc: -1,
+			Func: encodeJwt,
+		},
+		"msgpack": {
+			Argc: 1,
+			Func: encodeBinary(value.EncodeMsgpack),
+		},
+		"ndjson": {
+			Argc: -1,
+			Func: encodeNdjson,
+		},
+		"protobuf": {
+			Argc: 3,
+			Func: encodeProtobuf,
+		},
+		"tsv": {
+			Argc: -1,
+			Func: encodeCsv('\t'),
+		},
 		"url": {
 			Argc: 1,
 			Func: encodeUrl,
 		},
+		"xml": {
+			Argc: 1,
+			Func: encodeXml,
+		},
+		"yaml": {
+			Argc: 1,
+			Func: encodeYaml,
+		},
+		"zstd": {
+			Argc: 1,
+			Func: encodeCompressed("zstd"),
+		},
 	}
 )
 
-func encodeBase64(cmd string, args []value.Value) (value.Value, error) {
-	arg0 := args[0]
+// textEncoder returns a writer that encodes the data written to it as text to
+// the given writer. If raw is true, then the text is not padded.
+type textEncoder func(w io.Writer, raw bool) io.WriteCloser
 
-	var src bytes.Buffer
+func base64Encoder(enc *base64.Encoding) textEncoder {
+	return func(w io.Writer, raw bool) io.WriteCloser {
+		if raw {
+			return base64.NewEncoder(enc.WithPadding(base64.NoPadding), w)
+		}
+		return base64.NewEncoder(enc, w)
+	}
+}
+
+func base32Encoder(enc *base32.Encoding) textEncoder {
+	return func(w io.Writer, raw bool) io.WriteCloser {
+		if raw {
+			return base32.NewEncoder(enc.WithPadding(base32.NoPadding), w)
+		}
+		return base32.NewEncoder(enc, w)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// hexEncoder encodes to hex, which has no padding so raw has no effect.
+func hexEncoder(w io.Writer, _ bool) io.WriteCloser {
+	return nopWriteCloser{Writer: hex.NewEncoder(w)}
+}
+
+// encodeText returns a command function for encoding a stream or string as
+// text. This takes an optional raw flag before the value to encode without
+// padding.
+func encodeText(newEncoder textEncoder) CommandFunc {
+	return func(cmd string, args []value.Value) (value.Value, error) {
+		if l := len(args); l < 1 || l > 2 {
+			err := errNotEnoughArgs
+
+			if l > 2 {
+				err = errTooManyArgs
+			}
 
-	switch v := arg0.(type) {
-	case value.String:
-		src.WriteString(v.Value)
-	case value.Stream:
-		if _, err := io.Copy(&src, v); err != nil {
 			return nil, &CommandError{
+				Op:  "call",
 				Cmd: cmd,
 				Err: err,
 			}
 		}
 
-		if _, err := v.Seek(0, io.SeekStart); err != nil {
+		var raw bool
+
+		if len(args) > 1 {
+			name, err := value.ToName(args[0])
+
+			if err != nil {
+				return nil, &CommandError{
+					Cmd: cmd,
+					Err: err,
+				}
+			}
+
+			if name.Value != "raw" {
+				return nil, &CommandError{
+					Cmd: cmd,
+					Err: errors.New("unknown flag " + name.Value),
+				}
+			}
+
+			raw = true
+			args = args[1:]
+		}
+
+		arg0 := args[0]
+
+		var src io.Reader
+
+		switch v := arg0.(type) {
+		case value.String:
+			src = strings.NewReader(v.Value)
+		case value.Stream:
+			src = v
+		default:
 			return nil, &CommandError{
 				Cmd: cmd,
-				Err: err,
+				Err: errors.New("cannot encode " + value.Type(arg0)),
 			}
 		}
-	default:
-		return nil, &CommandError{
-			Cmd: cmd,
-			Err: errors.New("cannot encode " + value.Type(arg0)),
-		}
-	}
 
-	var buf bytes.Buffer
+		var buf bytes.Buffer
 
-	enc := base64.NewEncoder(base64.StdEncoding, &buf)
+		enc := newEncoder(&buf, raw)
 
-	if _, err := io.Copy(enc, &src); err != nil {
-		return nil, &CommandError{
-			Cmd: cmd,
-			Err: err,
+		if _, err := io.Copy(enc, src); err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
 		}
-	}
 
-	if err := enc.Close(); err != nil {
-		return nil, &CommandError{
-			Cmd: cmd,
-			Err: err,
+		if err := enc.Close(); err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		if s, ok := arg0.(value.Stream); ok {
+			if _, err := s.Seek(0, io.SeekStart); err != nil {
+				return nil, &CommandError{
+					Cmd: cmd,
+					Err: err,
+				}
+			}
 		}
+
+		return value.String{
+			Value: buf.String(),
+		}, nil
 	}
-	return value.String{
-		Value: buf.String(),
-	}, nil
 }
 
 func encodeFormData(boundary string) CommandFunc {
@@ -1035,20 +1325,114 @@ func encodeUrl(cmd string, args []value.Value) (value.Value, error) {
 	}, nil
 }
 
+// getJSONOptions returns the options for encoding JSON from the given object.
+func getJSONOptions(obj *value.Object) (value.JSONOptions, error) {
+	opts := value.JSONOptions{
+		EscapeHTML: true,
+	}
+
+	if val, ok := obj.Pairs["Indent"]; ok {
+		switch v := val.(type) {
+		case value.Int:
+			opts.Indent = strings.Repeat(" ", int(v.Value))
+		case value.String:
+			opts.Indent = v.Value
+		default:
+			return opts, errors.New("key error Indent: cannot use " + value.Type(val) + " as int or string")
+		}
+	}
+
+	fields := []struct {
+		key string
+		p   *bool
+	}{
+		{"SortKeys", &opts.SortKeys},
+		{"Canonical", &opts.Canonical},
+	}
+
+	for _, fld := range fields {
+		b, err := getBool(obj, fld.key)
+
+		if err != nil {
+			return opts, err
+		}
+		*fld.p = b
+	}
+
+	if _, ok := obj.Pairs["EscapeHTML"]; ok {
+		b, err := getBool(obj, "EscapeHTML")
+
+		if err != nil {
+			return opts, err
+		}
+		opts.EscapeHTML = b
+	}
+	return opts, nil
+}
+
+// jsonArgs returns the JSON options, and the operand from the given
+// arguments. An object of options can be given as the first argument.
+func jsonArgs(cmd string, args []value.Value) (value.JSONOptions, value.Value, error) {
+	opts := value.JSONOptions{
+		EscapeHTML: true,
+	}
+
+	if l := len(args); l < 1 || l > 2 {
+		err := errNotEnoughArgs
+
+		if l > 2 {
+			err = errTooManyArgs
+		}
+
+		return opts, nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	if len(args) > 1 {
+		obj, err := value.ToObject(args[0])
+
+		if err != nil {
+			return opts, nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		opts, err = getJSONOptions(obj)
+
+		if err != nil {
+			return opts, nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+	}
+	return opts, args[len(args)-1], nil
+}
+
+// encodeJson encodes the last argument to JSON. An object of options can be
+// given as the first argument to configure the encoding.
 func encodeJson(cmd string, args []value.Value) (value.Value, error) {
-	arg0 := args[0]
+	opts, arg, err := jsonArgs(cmd, args)
 
-	switch arg0.(type) {
+	if err != nil {
+		return nil, err
+	}
+
+	switch arg.(type) {
 	case *value.Array:
 	case *value.Object:
 	default:
 		return nil, &CommandError{
 			Cmd: cmd,
-			Err: errors.New("cannot encode " + value.Type(arg0)),
+			Err: errors.New("cannot encode " + value.Type(arg)),
 		}
 	}
 
-	b, err := json.Marshal(arg0)
+	b, err := value.EncodeJSON(arg, opts)
 
 	if err != nil {
 		return nil, &CommandError{
@@ -1062,22 +1446,218 @@ func encodeJson(cmd string, args []value.Value) (value.Value, error) {
 	}, nil
 }
 
-func encode(cmd string, args []value.Value) (value.Value, error) {
-	name, err := value.ToName(args[0])
+// encodeNdjson encodes each item in the last argument to JSON on its own line.
+// This takes the same options as encodeJson, except for the indentation.
+func encodeNdjson(cmd string, args []value.Value) (value.Value, error) {
+	opts, arg, err := jsonArgs(cmd, args)
 
 	if err != nil {
 		return nil, err
 	}
 
-	subcmd, ok := encodetab[name.Value]
+	if _, ok := arg.(*value.Array); !ok {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("cannot encode " + value.Type(arg)),
+		}
+	}
 
-	if !ok {
-		return nil, errors.New("undefined command: " + cmd + " " + name.Value)
+	b, err := value.EncodeNDJSON(arg, opts)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
 	}
 
-	subcmd.Name = cmd + " " + name.Value
+	return value.String{
+		Value: string(b),
+	}, nil
+}
+
+// encodeBinary returns a command function that encodes the first argument
+// with the given encode function. The encoded data is returned as a stream so
+// it can be used as a request body.
+func encodeBinary(encode func(value.Value) ([]byte, error)) CommandFunc {
+	return func(cmd string, args []value.Value) (value.Value, error) {
+		b, err := encode(args[0])
 
-	return subcmd.invoke(args[1:])
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+		return value.NewStream(value.BufferStream(bytes.NewReader(b))), nil
+	}
+}
+
+// getCSVOptions returns the CSV options from the given object. The given
+// comma is used as the delimiter if none is set in the object.
+func getCSVOptions(obj *value.Object, comma rune) (value.CSVOptions, error) {
+	opts := value.CSVOptions{
+		Comma:  comma,
+		Header: true,
+	}
+
+	delim, err := getString(obj, "Delimiter")
+
+	if err != nil {
+		return opts, err
+	}
+
+	if delim != "" {
+		r := []rune(delim)
+
+		if len(r) != 1 {
+			return opts, errors.New("key error Delimiter: must be a single character")
+		}
+		opts.Comma = r[0]
+	}
+
+	if _, ok := obj.Pairs["Header"]; ok {
+		b, err := getBool(obj, "Header")
+
+		if err != nil {
+			return opts, err
+		}
+		opts.Header = b
+	}
+
+	opts.Lazy, err = getBool(obj, "Lazy")
+
+	if err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// csvArgs returns the CSV options, and the operand from the given arguments.
+// An object of options can be given as the first argument.
+func csvArgs(cmd string, comma rune, args []value.Value) (value.CSVOptions, value.Value, error) {
+	if l := len(args); l < 1 || l > 2 {
+		err := errNotEnoughArgs
+
+		if l > 2 {
+			err = errTooManyArgs
+		}
+
+		return value.CSVOptions{}, nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	opts := value.CSVOptions{
+		Comma:  comma,
+		Header: true,
+	}
+
+	if len(args) > 1 {
+		obj, err := value.ToObject(args[0])
+
+		if err != nil {
+			return opts, nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		opts, err = getCSVOptions(obj, comma)
+
+		if err != nil {
+			return opts, nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+	}
+	return opts, args[len(args)-1], nil
+}
+
+// encodeCsv returns a command function for encoding an array to CSV using the
+// given delimiter.
+func encodeCsv(comma rune) CommandFunc {
+	return func(cmd string, args []value.Value) (value.Value, error) {
+		opts, arg, err := csvArgs(cmd, comma, args)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := arg.(*value.Array); !ok {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: errors.New("cannot encode " + value.Type(arg)),
+			}
+		}
+
+		b, err := value.EncodeCSV(arg, opts)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		return value.String{
+			Value: string(b),
+		}, nil
+	}
+}
+
+func encodeXml(cmd string, args []value.Value) (value.Value, error) {
+	arg0 := args[0]
+
+	if _, ok := arg0.(*value.Object); !ok {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("cannot encode " + value.Type(arg0)),
+		}
+	}
+
+	b, err := value.EncodeXML(arg0)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	return value.String{
+		Value: string(b),
+	}, nil
+}
+
+func encodeYaml(cmd string, args []value.Value) (value.Value, error) {
+	arg0 := args[0]
+
+	switch arg0.(type) {
+	case *value.Array:
+	case *value.Object:
+	default:
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("cannot encode " + value.Type(arg0)),
+		}
+	}
+
+	b, err := value.EncodeYAML(arg0)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	return value.String{
+		Value: strings.TrimSuffix(string(b), "\n"),
+	}, nil
 }
 
 // DecodeCmd implements the decode family of commands for decoding data back to
@@ -1086,58 +1666,138 @@ func encode(cmd string, args []value.Value) (value.Value, error) {
 var (
 	DecodeCmd = &Command{
 		Name: "decode",
-		Argc: 2,
-		Func: decode,
+		Argc: -1,
+		Func: family(decodetab),
 	}
 
 	decodetab = map[string]*Command{
+		"base32": {
+			Argc: 1,
+			Func: decodeText(base32Decoder(base32.StdEncoding)),
+		},
 		"base64": {
 			Argc: 1,
-			Func: decodeBase64,
+			Func: decodeText(base64Decoder(base64.StdEncoding)),
+		},
+		"base64url": {
+			Argc: 1,
+			Func: decodeText(base64Decoder(base64.URLEncoding)),
+		},
+		"br": {
+			Argc: 1,
+			Func: decodeCompressed("br"),
+		},
+		"cbor": {
+			Argc: 1,
+			Func: decodeReader(value.DecodeCBOR),
+		},
+		"csv": {
+			Argc: -1,
+			Func: decodeCsv(','),
+		},
+		"deflate": {
+			Argc: 1,
+			Func: decodeCompressed("deflate"),
 		},
 		"form-data": {
 			Argc: 1,
 			Func: decodeFormData,
 		},
+		"gzip": {
+			Argc: 1,
+			Func: decodeCompressed("gzip"),
+		},
+		"hex": {
+			Argc: 1,
+			Func: decodeText(hexDecoder),
+		},
 		"json": {
 			Argc: 1,
-			Func: decodeJson,
+			Func: decodeReader(value.DecodeJSON),
+		},
+		"jwt": {
+			Argc: -1,
+			Func: decodeJwt,
+		},
+		"msgpack": {
+			Argc: 1,
+			Func: decodeReader(value.DecodeMsgpack),
+		},
+		"ndjson": {
+			Argc: 1,
+			Func: decodeNdjson,
+		},
+		"protobuf": {
+			Argc: 3,
+			Func: decodeProtobuf,
+		},
+		"tsv": {
+			Argc: -1,
+			Func: decodeCsv('\t'),
 		},
 		"url": {
 			Argc: 1,
 			Func: decodeUrl,
 		},
+		"xml": {
+			Argc: 1,
+			Func: decodeReader(value.DecodeXML),
+		},
+		"yaml": {
+			Argc: 1,
+			Func: decodeReader(value.DecodeYAML),
+		},
+		"zstd": {
+			Argc: 1,
+			Func: decodeCompressed("zstd"),
+		},
 	}
 )
 
-func decodeBase64(cmd string, args []value.Value) (value.Value, error) {
-	arg0 := args[0]
+// unpad returns the given text with any line breaks, and trailing padding
+// removed.
+func unpad(b []byte) string {
+	s := strings.NewReplacer("\r", "", "\n", "").Replace(string(b))
+	return strings.TrimRight(s, "=")
+}
 
-	var r io.Reader
+func base64Decoder(enc *base64.Encoding) func([]byte) ([]byte, error) {
+	enc = enc.WithPadding(base64.NoPadding)
 
-	switch v := arg0.(type) {
-	case value.String:
-		r = strings.NewReader(v.Value)
-	case value.Stream:
-		r = v
-	default:
-		return nil, &CommandError{
-			Cmd: cmd,
-			Err: errors.New("cannot decode " + value.Type(arg0)),
-		}
+	return func(b []byte) ([]byte, error) {
+		return enc.DecodeString(unpad(b))
 	}
+}
 
-	dec := base64.NewDecoder(base64.StdEncoding, r)
+func base32Decoder(enc *base32.Encoding) func([]byte) ([]byte, error) {
+	enc = enc.WithPadding(base32.NoPadding)
 
-	b, err := io.ReadAll(dec)
+	return func(b []byte) ([]byte, error) {
+		return enc.DecodeString(unpad(b))
+	}
+}
 
-	if err != nil {
-		return nil, &CommandError{
-			Cmd: cmd,
-			Err: err,
+func hexDecoder(b []byte) ([]byte, error) {
+	return hex.DecodeString(strings.TrimSpace(string(b)))
+}
+
+// decodeText returns a command function for decoding a stream or string of
+// text into a stream. The text can be either with, or without padding.
+func decodeText(decode func([]byte) ([]byte, error)) CommandFunc {
+	return decodeReader(func(r io.Reader) (value.Value, error) {
+		b, err := io.ReadAll(r)
+
+		if err != nil {
+			return nil, err
 		}
-	}
-	return value.NewStream(value.BufferStream(bytes.NewReader(b))), nil
+
+		b, err = decode(b)
+
+		if err != nil {
+			return nil, err
+		}
+		return value.NewStream(value.BufferStream(bytes.NewReader(b))), nil
+	})
 }
 
 var maxFormMemory int64 = 64 << 20 // 64 MB
@@ -1200,9 +1860,50 @@ func decodeFormData(cmd string, args []value.Value) (value.Value, error) {
 	return obj, nil
 }
 
-func decodeJson(cmd string, args []value.Value) (value.Value, error) {
-	arg0 := args[0]
+// decodeCsv returns a command function for decoding CSV using the given
+// delimiter. The records are decoded into an array, unless the Lazy option is
+// set for a stream, in which case the stream is decoded into an iterator that
+// decodes each record as it is iterated over.
+func decodeCsv(comma rune) CommandFunc {
+	return func(cmd string, args []value.Value) (value.Value, error) {
+		opts, arg, err := csvArgs(cmd, comma, args)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if s, ok := arg.(value.Stream); ok && opts.Lazy {
+			return value.IterateCSV(s, opts), nil
+		}
 
+		return decodeFrom(cmd, arg, func(r io.Reader) (value.Value, error) {
+			return value.DecodeCSV(r, opts)
+		})
+	}
+}
+
+// decodeNdjson decodes newline delimited JSON. A string is decoded into an
+// array of values, whereas a stream is decoded into an iterator that decodes
+// each value as it is iterated over.
+func decodeNdjson(cmd string, args []value.Value) (value.Value, error) {
+	if s, ok := args[0].(value.Stream); ok {
+		return value.IterateNDJSON(s), nil
+	}
+	return decodeFrom(cmd, args[0], value.DecodeNDJSON)
+}
+
+// decodeReader returns a command function that decodes the string or stream
+// in the first argument with the given decode function. Streams are rewound
+// once decoded.
+func decodeReader(decode func(io.Reader) (value.Value, error)) CommandFunc {
+	return func(cmd string, args []value.Value) (value.Value, error) {
+		return decodeFrom(cmd, args[0], decode)
+	}
+}
+
+// decodeFrom decodes the given string or stream with the given decode
+// function. Streams are rewound once decoded.
+func decodeFrom(cmd string, arg0 value.Value, decode func(io.Reader) (value.Value, error)) (value.Value, error) {
 	var (
 		r      io.Reader
 		stream value.Stream
@@ -1221,7 +1922,7 @@ func decodeJson(cmd string, args []value.Value) (value.Value, error) {
 		}
 	}
 
-	val, err := value.DecodeJSON(r)
+	val, err := decode(r)
 
 	if err != nil {
 		return nil, &CommandError{
@@ -1306,21 +2007,3 @@ func decodeUrl(cmd string, args []value.Value) (value.Value, error) {
 	}
 	return obj, nil
 }
-
-func decode(cmd string, args []value.Value) (value.Value, error) {
-	name, err := value.ToName(args[0])
-
-	if err != nil {
-		return nil, err
-	}
-
-	subcmd, ok := decodetab[name.Value]
-
-	if !ok {
-		return nil, errors.New("undefined command: " + cmd + " " + name.Value)
-	}
-
-	subcmd.Name = cmd + " " + name.Value
-
-	return subcmd.invoke(args[1:])
-}
@@ -0,0 +1,290 @@
+package eval
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andrewpillar/req/value"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DecompressCmd implements the decompress command for decompressing the body
+// of the response to a request based on its Content-Encoding.
+var DecompressCmd = &Command{
+	Name: "decompress",
+	Argc: 1,
+	Func: decompress,
+}
+
+// compressors is the table of the supported content codings, and the
+// functions for compressing data with them. The deflate coding is the zlib
+// format, as per RFC 9110.
+var compressors = map[string]func(io.Writer) (io.WriteCloser, error){
+	"br": func(w io.Writer) (io.WriteCloser, error) {
+		return brotli.NewWriter(w), nil
+	},
+	"deflate": func(w io.Writer) (io.WriteCloser, error) {
+		return zlib.NewWriter(w), nil
+	},
+	"gzip": func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	},
+	"zstd": func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w)
+	},
+}
+
+// decompressors is the table of the supported content codings, and the
+// functions for decompressing data with them.
+var decompressors = map[string]func(io.Reader) (io.ReadCloser, error){
+	"br": func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(brotli.NewReader(r)), nil
+	},
+	"deflate": inflate,
+	"gzip": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	"zstd": func(r io.Reader) (io.ReadCloser, error) {
+		dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	},
+}
+
+// maxDecompressedLen is the maximum length of the data that will be read from
+// a decompressor. This stops a small body from expanding without bound.
+const maxDecompressedLen = 64 << 20
+
+// errDecompressedLen is returned when the decompressed data would be longer
+// than maxDecompressedLen.
+var errDecompressedLen = errors.New("decompressed data too large")
+
+// readDecompressed reads all of the data from the given decompressor, up to
+// maxDecompressedLen.
+func readDecompressed(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxDecompressedLen+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) > maxDecompressedLen {
+		return nil, errDecompressedLen
+	}
+	return b, nil
+}
+
+// inflate returns a reader for deflate compressed data. Some servers send raw
+// deflate data rather than the zlib format, so this checks for the zlib header
+// first.
+func inflate(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+
+	hdr, err := br.Peek(2)
+
+	if err != nil && len(hdr) < 2 {
+		return flate.NewReader(br), nil
+	}
+
+	if hdr[0]&0x0f == 8 && (uint16(hdr[0])<<8|uint16(hdr[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+func encodeCompressed(coding string) CommandFunc {
+	return func(cmd string, args []value.Value) (value.Value, error) {
+		arg0 := args[0]
+
+		var src io.Reader
+
+		switch v := arg0.(type) {
+		case value.String:
+			src = strings.NewReader(v.Value)
+		case value.Stream:
+			src = v
+		default:
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: errors.New("cannot encode " + value.Type(arg0)),
+			}
+		}
+
+		var buf bytes.Buffer
+
+		w, err := compressors[coding](&buf)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		if _, err := io.Copy(w, src); err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		if err := w.Close(); err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		if s, ok := arg0.(value.Stream); ok {
+			if _, err := s.Seek(0, io.SeekStart); err != nil {
+				return nil, &CommandError{
+					Cmd: cmd,
+					Err: err,
+				}
+			}
+		}
+		return value.NewStream(value.BufferStream(bytes.NewReader(buf.Bytes()))), nil
+	}
+}
+
+func decodeCompressed(coding string) CommandFunc {
+	return decodeReader(func(r io.Reader) (value.Value, error) {
+		rc, err := decompressors[coding](r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer rc.Close()
+
+		b, err := readDecompressed(rc)
+
+		if err != nil {
+			return nil, err
+		}
+		return value.NewStream(value.BufferStream(bytes.NewReader(b))), nil
+	})
+}
+
+// acceptEncoding is the value of the Accept-Encoding header sent for requests
+// that are decompressed.
+const acceptEncoding = "gzip, deflate, br, zstd"
+
+// emptyBody reports whether the given response has no body to decompress.
+// This is the case for responses to HEAD requests, and for 204 and 304
+// responses, which may still carry the Content-Encoding of the resource. Any
+// other body is peeked to check that it is not empty.
+func emptyBody(r *http.Request, resp *http.Response) bool {
+	if r.Method == http.MethodHead || resp.ContentLength == 0 {
+		return true
+	}
+
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return true
+	}
+
+	br := bufio.NewReader(resp.Body)
+
+	_, err := br.Peek(1)
+
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{br, resp.Body}
+
+	return err == io.EOF
+}
+
+// decompressTransport returns middleware that decompresses the body of the
+// response based on its Content-Encoding. Multiple codings are removed in the
+// reverse order they were applied. Responses without a body are returned as
+// is.
+func decompressTransport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		resp, err := rt.RoundTrip(r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if emptyBody(r, resp) {
+			return resp, nil
+		}
+
+		codings := strings.Split(resp.Header.Get("Content-Encoding"), ",")
+
+		body := resp.Body
+
+		for i := len(codings) - 1; i >= 0; i-- {
+			coding := strings.ToLower(strings.TrimSpace(codings[i]))
+
+			if coding == "" || coding == "identity" {
+				continue
+			}
+
+			fn, ok := decompressors[coding]
+
+			if !ok {
+				resp.Body.Close()
+				return nil, errors.New("unsupported content encoding " + coding)
+			}
+
+			rc, err := fn(body)
+
+			if err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+
+			b, err := readDecompressed(rc)
+
+			rc.Close()
+
+			if err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			body = io.NopCloser(bytes.NewReader(b))
+		}
+
+		if body != resp.Body {
+			resp.Body.Close()
+
+			resp.Body = body
+			resp.Header.Del("Content-Encoding")
+			resp.Header.Del("Content-Length")
+			resp.ContentLength = -1
+			resp.Uncompressed = true
+		}
+		return resp, nil
+	})
+}
+
+func decompress(cmd string, args []value.Value) (value.Value, error) {
+	req, err := value.ToRequest(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	req.Use(decompressTransport)
+	return req, nil
+}
@@ -0,0 +1,486 @@
+package eval
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/andrewpillar/req/value"
+)
+
+// SignCmd implements the sign family of commands for signing data. Each sign
+// command has a respective verify command for verifying the signature.
+var (
+	SignCmd = &Command{
+		Name: "sign",
+		Argc: -1,
+		Func: family(signtab),
+	}
+
+	signtab = map[string]*Command{
+		"ecdsa": {
+			Argc: -1,
+			Func: signData("ecdsa"),
+		},
+		"ed25519": {
+			Argc: 2,
+			Func: signData("ed25519"),
+		},
+		"httpsig": {
+			Argc: 2,
+			Func: signHTTPSig,
+		},
+		"rsa-pss": {
+			Argc: -1,
+			Func: signData("rsa-pss"),
+		},
+		"webhook": {
+			Argc: 2,
+			Func: signWebhook,
+		},
+	}
+)
+
+// VerifyCmd implements the verify family of commands for verifying the
+// signatures of data. Each of these commands returns a bool for whether the
+// signature is valid.
+var (
+	VerifyCmd = &Command{
+		Name: "verify",
+		Argc: -1,
+		Func: family(verifytab),
+	}
+
+	verifytab = map[string]*Command{
+		"ecdsa": {
+			Argc: -1,
+			Func: verifyData("ecdsa"),
+		},
+		"ed25519": {
+			Argc: 3,
+			Func: verifyData("ed25519"),
+		},
+		"httpsig": {
+			Argc: 2,
+			Func: verifyHTTPSig,
+		},
+		"rsa-pss": {
+			Argc: -1,
+			Func: verifyData("rsa-pss"),
+		},
+		"webhook": {
+			Argc: 2,
+			Func: verifyWebhook,
+		},
+	}
+)
+
+// hashtab is the table of hash functions that can be referred to by name.
+var hashtab = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// readBytes returns all of the bytes in the given string or stream. If a
+// stream is given, then it is rewound once read.
+func readBytes(val value.Value) ([]byte, error) {
+	switch v := val.(type) {
+	case value.String:
+		return []byte(v.Value), nil
+	case value.Stream:
+		b, err := io.ReadAll(v)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := v.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return b, nil
+	default:
+		return nil, errors.New("cannot use type " + value.Type(val) + " as string or stream")
+	}
+}
+
+// readPEM returns the first PEM block in the given value. The value can either
+// be a string of the PEM encoded data, a path to a file containing the PEM
+// encoded data, or a stream of the PEM encoded data.
+func readPEM(val value.Value) (*pem.Block, error) {
+	b, err := readBytes(val)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := val.(value.String); ok && !bytes.Contains(b, []byte("-----BEGIN")) {
+		b, err = os.ReadFile(string(b))
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	block, _ := pem.Decode(b)
+
+	if block == nil {
+		return nil, errors.New("no PEM data found in key")
+	}
+	return block, nil
+}
+
+// parsePrivateKey parses the PEM encoded private key in the given value. This
+// supports PKCS #1 and SEC 1 keys, and PKCS #8 wrapped RSA, ECDSA, and Ed25519
+// keys.
+func parsePrivateKey(val value.Value) (crypto.Signer, error) {
+	block, err := readPEM(val)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		signer, ok := key.(crypto.Signer)
+
+		if !ok {
+			return nil, errors.New("unsupported private key")
+		}
+		return signer, nil
+	default:
+		return nil, errors.New("unexpected PEM block " + block.Type + " for private key")
+	}
+}
+
+// parsePublicKey parses the PEM encoded public key in the given value. If the
+// value is a certificate, then the public key of that certificate is returned.
+// If the value is a private key, then the public key of that private key is
+// returned.
+func parsePublicKey(val value.Value) (crypto.PublicKey, error) {
+	block, err := readPEM(val)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			key, err := parsePrivateKey(val)
+
+			if err != nil {
+				return nil, err
+			}
+			return key.Public(), nil
+		}
+		return nil, errors.New("unexpected PEM block " + block.Type + " for public key")
+	}
+}
+
+// keyType returns the name of the type of the given key for use in error
+// messages.
+func keyType(key interface{}) string {
+	switch key.(type) {
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return "RSA"
+	case *ecdsa.PrivateKey, *ecdsa.PublicKey:
+		return "ECDSA"
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return "unknown"
+	}
+}
+
+// checkKey checks that the given public key can be used for the given
+// signature algorithm.
+func checkKey(alg string, key crypto.PublicKey) error {
+	var ok bool
+
+	switch alg {
+	case "ecdsa":
+		_, ok = key.(*ecdsa.PublicKey)
+	case "ed25519":
+		_, ok = key.(ed25519.PublicKey)
+	case "rsa-pss":
+		_, ok = key.(*rsa.PublicKey)
+	}
+
+	if !ok {
+		return errors.New("cannot use " + keyType(key) + " key for " + alg)
+	}
+	return nil
+}
+
+// signatureHashes is the table of hash functions that can be used with ECDSA
+// and RSA-PSS signatures.
+var signatureHashes = map[string]crypto.Hash{
+	"sha256": crypto.SHA256,
+	"sha384": crypto.SHA384,
+	"sha512": crypto.SHA512,
+}
+
+// signArgs returns the key, hash, and remaining arguments from the given
+// arguments to a sign or verify command, where n is the number of arguments
+// without options. The options are given as an optional object after the key,
+// and the hash is zero if no Hash is set in them.
+func signArgs(cmd string, args []value.Value, n int) (value.Value, crypto.Hash, []value.Value, error) {
+	if l := len(args); l < n || l > n+1 {
+		err := errNotEnoughArgs
+
+		if l > n+1 {
+			err = errTooManyArgs
+		}
+
+		return nil, 0, nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	if len(args) == n {
+		return args[0], 0, args[1:], nil
+	}
+
+	obj, err := value.ToObject(args[1])
+
+	if err != nil {
+		return nil, 0, nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	hash, err := getString(obj, "Hash")
+
+	if err != nil {
+		return nil, 0, nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	var h crypto.Hash
+
+	if hash != "" {
+		var ok bool
+
+		h, ok = signatureHashes[hash]
+
+		if !ok {
+			return nil, 0, nil, &CommandError{
+				Cmd: cmd,
+				Err: errors.New("key error Hash: unsupported hash " + hash),
+			}
+		}
+	}
+	return args[0], h, args[2:], nil
+}
+
+// signatureHash returns the hash used for signing with the given key, if no
+// hash was given. For ECDSA keys this is picked based on the size of the
+// curve, otherwise SHA-256 is used.
+func signatureHash(key crypto.PublicKey, h crypto.Hash) crypto.Hash {
+	if h != 0 {
+		return h
+	}
+
+	if pub, ok := key.(*ecdsa.PublicKey); ok {
+		switch pub.Curve.Params().BitSize {
+		case 384:
+			return crypto.SHA384
+		case 521:
+			return crypto.SHA512
+		}
+	}
+	return crypto.SHA256
+}
+
+// digest returns the digest of the given string or stream using the given
+// hash.
+func digest(h crypto.Hash, val value.Value) ([]byte, error) {
+	hash := h.New()
+
+	if err := writeHash(hash, val); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// signData returns the command for signing data with the given algorithm. The
+// signature is returned as a stream of the raw bytes. The hash used for ECDSA
+// and RSA-PSS can be set via an optional object of options. Ed25519 signs the whole
+// message, so streams are read in full, otherwise streams are hashed as they
+// are read.
+func signData(alg string) CommandFunc {
+	return func(cmd string, args []value.Value) (value.Value, error) {
+		keyval, h, args, err := signArgs(cmd, args, 2)
+
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := parsePrivateKey(keyval)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		if err := checkKey(alg, key.Public()); err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		var (
+			msg  []byte
+			opts crypto.SignerOpts = crypto.Hash(0)
+		)
+
+		if alg == "ed25519" {
+			msg, err = readBytes(args[0])
+		} else {
+			h = signatureHash(key.Public(), h)
+			opts = h
+
+			if alg == "rsa-pss" {
+				opts = &rsa.PSSOptions{
+					SaltLength: rsa.PSSSaltLengthEqualsHash,
+					Hash:       h,
+				}
+			}
+			msg, err = digest(h, args[0])
+		}
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		sig, err := key.Sign(rand.Reader, msg, opts)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+		return value.NewStream(value.BufferStream(bytes.NewReader(sig))), nil
+	}
+}
+
+// verifyData returns the command for verifying the signature of data with the
+// given algorithm.
+func verifyData(alg string) CommandFunc {
+	return func(cmd string, args []value.Value) (value.Value, error) {
+		keyval, h, args, err := signArgs(cmd, args, 3)
+
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := parsePublicKey(keyval)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		if err := checkKey(alg, key); err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		sig, err := readBytes(args[0])
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		if alg == "ed25519" {
+			msg, err := readBytes(args[1])
+
+			if err != nil {
+				return nil, &CommandError{
+					Cmd: cmd,
+					Err: err,
+				}
+			}
+			return value.Bool{Value: ed25519.Verify(key.(ed25519.PublicKey), msg, sig)}, nil
+		}
+
+		h = signatureHash(key, h)
+
+		sum, err := digest(h, args[1])
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		var ok bool
+
+		switch pub := key.(type) {
+		case *ecdsa.PublicKey:
+			ok = ecdsa.VerifyASN1(pub, sum, sig)
+		case *rsa.PublicKey:
+			ok = rsa.VerifyPSS(pub, h, sum, sig, &rsa.PSSOptions{
+				SaltLength: rsa.PSSSaltLengthAuto,
+			}) == nil
+		}
+		return value.Bool{Value: ok}, nil
+	}
+}
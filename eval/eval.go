@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"hash/fnv"
 	"io"
+	"regexp"
 	"strconv"
 	"time"
 	"unicode/utf8"
@@ -69,6 +70,10 @@ type Error struct {
 func (e Error) Unwrap() error { return e.Err }
 func (e Error) Error() string { return e.Pos.String() + " - " + e.Err.Error() }
 
+// ErrPollTimeout is the error returned when the timeout of a poll statement
+// passes before its condition is met.
+var ErrPollTimeout = errors.New("poll timed out")
+
 type Evaluator struct {
 	cmds map[string]*Command
 
@@ -79,14 +84,38 @@ type Evaluator struct {
 }
 
 var builtinCmds = []*Command{
+	AppendCmd,
+	AuthCmd,
+	ContentDigestCmd,
 	CookieCmd,
 	DecodeCmd,
+	DecompressCmd,
+	DecryptCmd,
+	DelCmd,
 	EncodeCmd,
+	EncryptCmd,
 	EnvCmd,
 	ExitCmd,
+	FilterCmd,
+	FormatCmd,
+	GraphQLCmd,
+	GroupCmd,
+	GrpcCmd,
+	HashCmd,
+	HmacCmd,
+	KeysCmd,
+	LenCmd,
+	MapCmd,
+	MergeCmd,
+	NowCmd,
+	OAuth2Cmd,
 	OpenCmd,
+	ParseCmd,
+	PrettyCmd,
+	QueryCmd,
 	ReadCmd,
 	ReadlnCmd,
+	RegexCmd,
 	WriteCmd,
 	WritelnCmd,
 	HeadCmd,
@@ -98,8 +127,19 @@ var builtinCmds = []*Command{
 	DeleteCmd,
 	TlsCmd,
 	SendCmd,
+	SigV4Cmd,
+	SignCmd,
+	SleepCmd,
+	SliceCmd,
 	SniffCmd,
+	SortCmd,
+	StrCmd,
+	SumCmd,
+	UniqCmd,
 	UuidCmd,
+	ValuesCmd,
+	VerifyCmd,
+	XPathCmd,
 }
 
 // New returns a new evaluator for evaluating req scripts. The given writer is
@@ -404,6 +444,11 @@ func (e *Evaluator) evalRange(c *Context, n *syntax.Range, body *syntax.BlockStm
 		return nil, e.err(n.Right.Pos(), err)
 	}
 
+	// Make sure an iterator over a stream is rewound if the loop ends early.
+	if it, ok := iter.(*value.Iterator); ok {
+		defer it.Stop()
+	}
+
 	list, ok := n.Left.(*syntax.ExprList)
 
 	if !ok {
@@ -420,6 +465,10 @@ func (e *Evaluator) evalRange(c *Context, n *syntax.Range, body *syntax.BlockStm
 
 loop:
 	for !errors.Is(err, io.EOF) {
+		if err != nil {
+			return nil, e.err(n.Right.Pos(), err)
+		}
+
 		if l >= 1 {
 			if err := e.evalAssign(c, false, list.Nodes[0], key); err != nil {
 				return nil, e.err(n.Pos(), err)
@@ -450,6 +499,95 @@ loop:
 	return nil, nil
 }
 
+// evalFunc returns a function value for the given function literal. Each call
+// to the function is evaluated in a copy of the given context, with the
+// parameter bound to the value the function is called with.
+func (e *Evaluator) evalFunc(c *Context, n *syntax.FuncLit) value.Func {
+	return value.Func{
+		Param: n.Param.Value,
+		Call: func(v value.Value) (value.Value, error) {
+			c2 := c.Copy()
+			c2.Put(n.Param.Value, v)
+
+			for _, n := range n.Stmts {
+				if _, err := e.Eval(c2, n); err != nil {
+					return nil, e.err(n.Pos(), err)
+				}
+			}
+
+			val, err := e.Eval(c2, n.Body)
+
+			if err != nil {
+				return nil, e.err(n.Body.Pos(), err)
+			}
+			return val, nil
+		},
+	}
+}
+
+// evalPoll evaluates the given poll statement. The Init statement is evaluated
+// in the given context, so that any variables it sets are accessible once
+// polling has finished. If the timeout passes before the condition is met,
+// then ErrPollTimeout is returned.
+func (e *Evaluator) evalPoll(c *Context, n *syntax.PollStmt) (value.Value, error) {
+	durations := make([]time.Duration, 0, 2)
+
+	for _, n := range []syntax.Node{n.Interval, n.Timeout} {
+		val, err := e.Eval(c, n)
+
+		if err != nil {
+			return nil, e.err(n.Pos(), err)
+		}
+
+		d, err := value.ToDuration(val)
+
+		if err != nil {
+			return nil, e.err(n.Pos(), err)
+		}
+		durations = append(durations, d.Value)
+	}
+
+	interval, timeout := durations[0], durations[1]
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		if _, err := e.Eval(c, n.Init); err != nil {
+			return nil, e.err(n.Pos(), err)
+		}
+
+		val, err := e.Eval(c, n.Cond)
+
+		if err != nil {
+			return nil, e.err(n.Pos(), err)
+		}
+
+		if value.Truthy(val) {
+			break
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return nil, e.err(n.Pos(), ErrPollTimeout)
+		}
+
+		if n.Body != nil {
+			if _, err := e.Eval(c, n.Body); err != nil {
+				branch, ok := err.(branchErr)
+
+				if !ok {
+					return nil, e.err(n.Body.Pos(), err)
+				}
+
+				if branch.kind == "break" {
+					break
+				}
+			}
+		}
+		time.Sleep(interval)
+	}
+	return nil, nil
+}
+
 // Eval Evaluates the given node and returns the value it Evaluates to if any.
 func (e *Evaluator) Eval(c *Context, n syntax.Node) (value.Value, error) {
 	switch v := n.(type) {
@@ -541,6 +679,8 @@ func (e *Evaluator) Eval(c *Context, n syntax.Node) (value.Value, error) {
 		case syntax.DurationLit:
 			d, _ := time.ParseDuration(v.Value)
 			return value.Duration{Value: d}, nil
+		case syntax.RegexLit:
+			return value.String{Value: v.Value}, nil
 		case syntax.BoolLit:
 			b := true
 
@@ -587,6 +727,8 @@ func (e *Evaluator) Eval(c *Context, n syntax.Node) (value.Value, error) {
 			Order: order,
 			Pairs: pairs,
 		}, nil
+	case *syntax.FuncLit:
+		return e.evalFunc(c, v), nil
 	case *syntax.BlockStmt:
 		// Create a copy so we can unset any variables that will fall out of
 		// scope of the block.
@@ -631,7 +773,16 @@ func (e *Evaluator) Eval(c *Context, n syntax.Node) (value.Value, error) {
 
 		jmptab := make(map[uint32]syntax.Node)
 
+		// Regular expression cases cannot be put in the jump table, so these
+		// are checked in order if nothing in the jump table matches.
+		regexes := make([]*syntax.CaseStmt, 0)
+
 		for _, stmt := range v.Cases {
+			if lit, ok := stmt.Value.(*syntax.Lit); ok && lit.Type == syntax.RegexLit {
+				regexes = append(regexes, stmt)
+				continue
+			}
+
 			h := fnv.New32a()
 
 			val, err := e.Eval(c, stmt.Value)
@@ -656,6 +807,24 @@ func (e *Evaluator) Eval(c *Context, n syntax.Node) (value.Value, error) {
 			return e.Eval(c, n)
 		}
 
+		for _, stmt := range regexes {
+			str, ok := condval.(value.String)
+
+			if !ok {
+				return nil, e.err(stmt.Pos(), errors.New("cannot match type "+value.Type(condval)+" against regex"))
+			}
+
+			re, err := regexp.Compile(stmt.Value.(*syntax.Lit).Value)
+
+			if err != nil {
+				return nil, e.err(stmt.Pos(), err)
+			}
+
+			if re.MatchString(str.Value) {
+				return e.Eval(c, stmt.Then)
+			}
+		}
+
 		if v.Default != nil {
 			return e.Eval(c, v.Default)
 		}
@@ -768,6 +937,8 @@ func (e *Evaluator) Eval(c *Context, n syntax.Node) (value.Value, error) {
 				}
 			}
 		}
+	case *syntax.PollStmt:
+		return e.evalPoll(c, v)
 	case *syntax.BranchStmt:
 		return nil, branchErr{kind: v.Tok.String(), pos: v.Pos()}
 	}
@@ -0,0 +1,268 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/andrewpillar/req/value"
+)
+
+// GraphQLCmd implements the graphql command for sending a GraphQL query to an
+// endpoint.
+var GraphQLCmd = &Command{
+	Name: "graphql",
+	Argc: -1,
+	Func: graphql,
+}
+
+// GraphQLLocation is the location in the query that a GraphQL error is for.
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLError is a single error returned in the response to a GraphQL query.
+// The Path is made up of the field names, and list indices that lead to the
+// field the error occurred on.
+type GraphQLError struct {
+	Message   string            `json:"message"`
+	Locations []GraphQLLocation `json:"locations"`
+	Path      []interface{}     `json:"path"`
+}
+
+func (e *GraphQLError) Error() string {
+	var buf strings.Builder
+
+	buf.WriteString(e.Message)
+
+	for _, loc := range e.Locations {
+		buf.WriteString(" at " + strconv.Itoa(loc.Line) + ":" + strconv.Itoa(loc.Column))
+	}
+
+	if len(e.Path) > 0 {
+		parts := make([]string, 0, len(e.Path))
+
+		for _, p := range e.Path {
+			parts = append(parts, fmt.Sprint(p))
+		}
+		buf.WriteString(" in " + strings.Join(parts, "."))
+	}
+	return buf.String()
+}
+
+// GraphQLErrors is the error returned by the graphql command when the response
+// contains errors. This is wrapped in a CommandError, so can be retrieved via
+// errors.As.
+type GraphQLErrors []*GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// graphqlQuery returns the query from the given value. If a stream is given,
+// or a string that is a path to a .graphql file, then the query is read from
+// that.
+func graphqlQuery(val value.Value) (string, error) {
+	if s, ok := val.(value.String); ok {
+		if ext := filepath.Ext(s.Value); ext == ".graphql" || ext == ".gql" {
+			b, err := os.ReadFile(s.Value)
+
+			if err != nil {
+				return "", err
+			}
+			return string(b), nil
+		}
+	}
+
+	b, err := readBytes(val)
+
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func graphql(cmd string, args []value.Value) (value.Value, error) {
+	if len(args) < 2 {
+		return nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: errNotEnoughArgs,
+		}
+	}
+
+	if len(args) > 4 {
+		return nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: errTooManyArgs,
+		}
+	}
+
+	req, ok := args[0].(*value.Request)
+
+	if !ok {
+		if _, err := value.ToString(args[0]); err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		val, err := request("POST", args[:1])
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+		req = val.(*value.Request)
+	}
+
+	query, err := graphqlQuery(args[1])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	payload := struct {
+		Query     string        `json:"query"`
+		Variables *value.Object `json:"variables,omitempty"`
+	}{Query: query}
+
+	if len(args) > 2 {
+		payload.Variables, err = value.ToObject(args[2])
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+	}
+
+	body, err := json.Marshal(payload)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	// Clone the request so it can be used for multiple queries.
+	r := req.Request.Clone(req.Context())
+	r.Method = "POST"
+	r.ContentLength = int64(len(body))
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	r.Header.Set("Content-Type", "application/json")
+
+	if r.Header.Get("Accept") == "" {
+		r.Header.Set("Accept", "application/graphql-response+json, application/json")
+	}
+
+	if len(args) > 3 {
+		hdr, err := value.ToObject(args[3])
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		for k, v := range hdr.Pairs {
+			str, err := value.ToString(v)
+
+			if err != nil {
+				return nil, &CommandError{
+					Cmd: cmd,
+					Err: err,
+				}
+			}
+			r.Header.Set(k, str.Value)
+		}
+	}
+
+	cli := http.Client{
+		Transport: req.RoundTripper(),
+	}
+
+	resp, err := cli.Do(r)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	defer resp.Body.Close()
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors GraphQLErrors   `json:"errors"`
+	}
+
+	// Servers may respond with a non-2xx status along with the errors, so
+	// decode the body first so those errors can be returned.
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			err = errors.New("unexpected response " + resp.Status)
+		}
+
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	if len(result.Errors) > 0 {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: result.Errors,
+		}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("unexpected response " + resp.Status),
+		}
+	}
+
+	if len(result.Data) == 0 {
+		return value.Zero{}, nil
+	}
+
+	data, err := value.DecodeJSON(bytes.NewReader(result.Data))
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,336 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/andrewpillar/req/value"
+)
+
+// GrpcCmd implements the grpc command for making a unary gRPC call using the
+// given request.
+var GrpcCmd = &Command{
+	Name: "grpc",
+	Argc: 4,
+	Func: grpc,
+}
+
+// grpcCodes is the list of gRPC status codes, indexed by their number.
+var grpcCodes = []string{
+	"OK",
+	"CANCELLED",
+	"UNKNOWN",
+	"INVALID_ARGUMENT",
+	"DEADLINE_EXCEEDED",
+	"NOT_FOUND",
+	"ALREADY_EXISTS",
+	"PERMISSION_DENIED",
+	"RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION",
+	"ABORTED",
+	"OUT_OF_RANGE",
+	"UNIMPLEMENTED",
+	"INTERNAL",
+	"UNAVAILABLE",
+	"DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+// h2cTransport is used for requests to plain HTTP endpoints, since gRPC
+// requires HTTP/2.
+var h2cTransport = func() http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Protocols = new(http.Protocols)
+	t.Protocols.SetUnencryptedHTTP2(true)
+	return t
+}()
+
+// loadProto loads the protobuf schema from the file at the given value.
+func loadProto(val value.Value) (*value.ProtoSchema, error) {
+	path, err := value.ToString(val)
+
+	if err != nil {
+		return nil, err
+	}
+	return value.LoadProto(path.Value)
+}
+
+// protoArgs returns the schema, and the name of the message from the given
+// arguments.
+func protoArgs(args []value.Value) (*value.ProtoSchema, string, error) {
+	schema, err := loadProto(args[0])
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	name, err := value.ToString(args[1])
+
+	if err != nil {
+		return nil, "", err
+	}
+	return schema, name.Value, nil
+}
+
+func encodeProtobuf(cmd string, args []value.Value) (value.Value, error) {
+	schema, name, err := protoArgs(args)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	b, err := schema.EncodeMessage(name, args[2])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return value.NewStream(value.BufferStream(bytes.NewReader(b))), nil
+}
+
+func decodeProtobuf(cmd string, args []value.Value) (value.Value, error) {
+	schema, name, err := protoArgs(args)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	return decodeFrom(cmd, args[2], func(r io.Reader) (value.Value, error) {
+		b, err := io.ReadAll(r)
+
+		if err != nil {
+			return nil, err
+		}
+		return schema.DecodeMessage(name, b)
+	})
+}
+
+// grpcMessage reads the single length-prefixed message from the body of a
+// unary call.
+func grpcMessage(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	if len(b) < 5 {
+		return nil, errors.New("malformed grpc message")
+	}
+
+	if b[0] != 0 {
+		return nil, errors.New("compressed grpc messages are not supported")
+	}
+
+	n := binary.BigEndian.Uint32(b[1:5])
+
+	if uint64(n) != uint64(len(b)-5) {
+		return nil, errors.New("malformed grpc message")
+	}
+	return b[5:], nil
+}
+
+// grpcHeader returns the first value of each field in the given header. Fields
+// without a value are skipped, such as trailers that were announced but not
+// sent.
+func grpcHeader(hdr http.Header) *value.Object {
+	obj := &value.Object{
+		Order: make([]string, 0, len(hdr)),
+		Pairs: make(map[string]value.Value),
+	}
+
+	for k, v := range hdr {
+		if len(v) == 0 {
+			continue
+		}
+
+		obj.Order = append(obj.Order, k)
+		obj.Pairs[k] = value.String{Value: v[0]}
+	}
+	return obj
+}
+
+func grpc(cmd string, args []value.Value) (value.Value, error) {
+	schema, err := loadProto(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	method, err := value.ToString(args[1])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	in, out, err := schema.Method(method.Value)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	msg, err := schema.EncodeMessage(in, args[2])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	req, err := value.ToRequest(args[3])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	body := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(msg)))
+	body = append(body, msg...)
+
+	// Clone the request so it can be used for multiple calls.
+	r := req.Request.Clone(req.Context())
+	r.Method = "POST"
+	r.URL.Path = strings.TrimSuffix(r.URL.Path, "/") + "/" + strings.TrimPrefix(method.Value, "/")
+	r.ContentLength = int64(len(body))
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	r.Header.Set("Content-Type", "application/grpc")
+	r.Header.Set("TE", "trailers")
+
+	call := *req
+
+	if t, ok := call.Transport.(*tlsTransport); ok {
+		call.Transport = t.http2()
+	} else if call.Transport == http.DefaultTransport && r.URL.Scheme == "http" {
+		call.Transport = h2cTransport
+	}
+
+	cli := http.Client{
+		Transport: call.RoundTripper(),
+	}
+
+	resp, err := cli.Do(r)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("unexpected response " + resp.Status),
+		}
+	}
+
+	if typ := resp.Header.Get("Content-Type"); !strings.HasPrefix(typ, "application/grpc") {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("unexpected content type " + typ),
+		}
+	}
+
+	b, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	// A response with no message may send the status in the headers, rather
+	// than the trailers.
+	status := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status")
+		message = resp.Header.Get("Grpc-Message")
+	}
+
+	code, err := strconv.Atoi(status)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("invalid grpc status " + strconv.Quote(status)),
+		}
+	}
+
+	if s, err := url.PathUnescape(message); err == nil {
+		message = s
+	}
+
+	name := "UNKNOWN"
+
+	if code >= 0 && code < len(grpcCodes) {
+		name = grpcCodes[code]
+	}
+
+	var data value.Value = value.Zero{}
+
+	if code == 0 {
+		p, err := grpcMessage(b)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		data, err = schema.DecodeMessage(out, p)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+	}
+
+	return &value.Object{
+		Order: []string{"Code", "Status", "Message", "Body", "Header", "Trailer"},
+		Pairs: map[string]value.Value{
+			"Code":    value.Int{Value: int64(code)},
+			"Status":  value.String{Value: name},
+			"Message": value.String{Value: message},
+			"Body":    data,
+			"Header":  grpcHeader(resp.Header),
+			"Trailer": grpcHeader(resp.Trailer),
+		},
+	}, nil
+}
@@ -0,0 +1,181 @@
+package eval
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/andrewpillar/req/value"
+)
+
+// HashCmd implements the hash family of commands for computing the digest of
+// data.
+var (
+	HashCmd = &Command{
+		Name: "hash",
+		Argc: -1,
+		Func: family(hashcmdtab),
+	}
+
+	hashcmdtab = func() map[string]*Command {
+		tab := hashCommands(hashfn)
+
+		// CRC-32 is a checksum rather than a cryptographic hash, so it is
+		// not in hashtab, and cannot be used for an HMAC.
+		tab["crc32"] = &Command{
+			Argc: -1,
+			Func: hashfn(func() hash.Hash { return crc32.NewIEEE() }),
+		}
+		return tab
+	}()
+)
+
+// HmacCmd implements the hmac family of commands for computing the HMAC of
+// data with a key.
+var (
+	HmacCmd = &Command{
+		Name: "hmac",
+		Argc: -1,
+		Func: family(hmaccmdtab),
+	}
+
+	hmaccmdtab = hashCommands(hmacfn)
+)
+
+// hashCommands returns a table of subcommands for each of the hash functions
+// in hashtab, using the given function to make the command for each.
+func hashCommands(fn func(func() hash.Hash) CommandFunc) map[string]*Command {
+	tab := make(map[string]*Command)
+
+	for name, h := range hashtab {
+		tab[name] = &Command{
+			Argc: -1,
+			Func: fn(h),
+		}
+	}
+	return tab
+}
+
+// writeHash writes the given string or stream to the hash. Streams are hashed
+// from the start, are copied into the hash as they are read, and are rewound
+// once read.
+func writeHash(h hash.Hash, val value.Value) error {
+	switch v := val.(type) {
+	case value.String:
+		io.WriteString(h, v.Value)
+	case value.Stream:
+		if _, err := v.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(h, v); err != nil {
+			return err
+		}
+
+		if _, err := v.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	default:
+		return errors.New("cannot use type " + value.Type(val) + " as string or stream")
+	}
+	return nil
+}
+
+// hashArgs returns the encoding for the sum, and the remaining arguments. The
+// encoding is given as an optional name before the other n arguments.
+func hashArgs(cmd string, n int, args []value.Value) (string, []value.Value, error) {
+	if l := len(args); l < n || l > n+1 {
+		err := errNotEnoughArgs
+
+		if l > n+1 {
+			err = errTooManyArgs
+		}
+
+		return "", nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	if len(args) == n {
+		return "hex", args, nil
+	}
+
+	name, err := value.ToName(args[0])
+
+	if err != nil {
+		return "", nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	if name.Value != "hex" && name.Value != "base64" {
+		return "", nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("unsupported encoding " + name.Value),
+		}
+	}
+	return name.Value, args[1:], nil
+}
+
+func encodeSum(h hash.Hash, enc string) value.Value {
+	if enc == "base64" {
+		return value.String{Value: base64.StdEncoding.EncodeToString(h.Sum(nil))}
+	}
+	return value.String{Value: hex.EncodeToString(h.Sum(nil))}
+}
+
+func hashfn(newhash func() hash.Hash) CommandFunc {
+	return func(cmd string, args []value.Value) (value.Value, error) {
+		enc, args, err := hashArgs(cmd, 1, args)
+
+		if err != nil {
+			return nil, err
+		}
+
+		h := newhash()
+
+		if err := writeHash(h, args[0]); err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+		return encodeSum(h, enc), nil
+	}
+}
+
+func hmacfn(newhash func() hash.Hash) CommandFunc {
+	return func(cmd string, args []value.Value) (value.Value, error) {
+		enc, args, err := hashArgs(cmd, 2, args)
+
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := readBytes(args[0])
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		h := hmac.New(newhash, key)
+
+		if err := writeHash(h, args[1]); err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+		return encodeSum(h, enc), nil
+	}
+}
@@ -0,0 +1,1024 @@
+package eval
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewpillar/req/value"
+)
+
+// ContentDigestCmd implements the content-digest command for setting the
+// Content-Digest header of a request, as described in RFC 9530.
+var ContentDigestCmd = &Command{
+	Name: "content-digest",
+	Argc: -1,
+	Func: contentDigestfn,
+}
+
+// digesttab is the table of algorithms that can be used for the
+// Content-Digest header.
+var digesttab = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// requestBody returns the body of the given request. The body of the request
+// is replaced so that it can be read again.
+func requestBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if r.GetBody != nil {
+		rc, err := r.GetBody()
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer rc.Close()
+
+		b, err := io.ReadAll(rc)
+
+		if err != nil {
+			return nil, err
+		}
+
+		r.Body, err = r.GetBody()
+
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+
+	b, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	return b, nil
+}
+
+// contentDigest returns the value of the Content-Digest header for the body
+// of the given request using the given algorithm.
+func contentDigest(r *http.Request, alg string) (string, error) {
+	newhash, ok := digesttab[alg]
+
+	if !ok {
+		return "", errors.New("unsupported digest algorithm " + alg)
+	}
+
+	b, err := requestBody(r)
+
+	if err != nil {
+		return "", err
+	}
+
+	h := newhash()
+	h.Write(b)
+
+	return alg + "=:" + base64.StdEncoding.EncodeToString(h.Sum(nil)) + ":", nil
+}
+
+func contentDigestfn(cmd string, args []value.Value) (value.Value, error) {
+	if l := len(args); l < 1 || l > 2 {
+		err := errNotEnoughArgs
+
+		if l > 2 {
+			err = errTooManyArgs
+		}
+
+		return nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	alg := "sha-256"
+
+	if len(args) > 1 {
+		name, err := value.ToName(args[0])
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+		alg = name.Value
+	}
+
+	req, err := value.ToRequest(args[len(args)-1])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	digest, err := contentDigest(req.Request, alg)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	req.Header.Set("Content-Digest", digest)
+	return req, nil
+}
+
+// httpsigAlg is an algorithm for HTTP Message Signatures, as described in
+// RFC 9421 section 3.3.
+type httpsigAlg struct {
+	sign   func(key value.Value, base []byte) ([]byte, error)
+	verify func(key value.Value, base, sig []byte) (bool, error)
+}
+
+var httpsigAlgs = map[string]httpsigAlg{
+	"hmac-sha256": {
+		sign: func(key value.Value, base []byte) ([]byte, error) {
+			secret, err := readBytes(key)
+
+			if err != nil {
+				return nil, err
+			}
+
+			h := hmac.New(sha256.New, secret)
+			h.Write(base)
+
+			return h.Sum(nil), nil
+		},
+		verify: func(key value.Value, base, sig []byte) (bool, error) {
+			secret, err := readBytes(key)
+
+			if err != nil {
+				return false, err
+			}
+
+			h := hmac.New(sha256.New, secret)
+			h.Write(base)
+
+			return hmac.Equal(h.Sum(nil), sig), nil
+		},
+	},
+	"ed25519": {
+		sign: func(key value.Value, base []byte) ([]byte, error) {
+			priv, err := parsePrivateKey(key)
+
+			if err != nil {
+				return nil, err
+			}
+
+			edkey, ok := priv.(ed25519.PrivateKey)
+
+			if !ok {
+				return nil, errors.New("cannot use " + keyType(priv) + " key for ed25519")
+			}
+			return ed25519.Sign(edkey, base), nil
+		},
+		verify: func(key value.Value, base, sig []byte) (bool, error) {
+			pub, err := parsePublicKey(key)
+
+			if err != nil {
+				return false, err
+			}
+
+			edkey, ok := pub.(ed25519.PublicKey)
+
+			if !ok {
+				return false, errors.New("cannot use " + keyType(pub) + " key for ed25519")
+			}
+			return ed25519.Verify(edkey, base, sig), nil
+		},
+	},
+	"rsa-pss-sha512": {
+		sign: func(key value.Value, base []byte) ([]byte, error) {
+			priv, err := parsePrivateKey(key)
+
+			if err != nil {
+				return nil, err
+			}
+
+			rsakey, ok := priv.(*rsa.PrivateKey)
+
+			if !ok {
+				return nil, errors.New("cannot use " + keyType(priv) + " key for rsa-pss-sha512")
+			}
+
+			sum := sha512.Sum512(base)
+
+			return rsa.SignPSS(rand.Reader, rsakey, crypto.SHA512, sum[:], &rsa.PSSOptions{
+				SaltLength: 64,
+			})
+		},
+		verify: func(key value.Value, base, sig []byte) (bool, error) {
+			pub, err := parsePublicKey(key)
+
+			if err != nil {
+				return false, err
+			}
+
+			rsakey, ok := pub.(*rsa.PublicKey)
+
+			if !ok {
+				return false, errors.New("cannot use " + keyType(pub) + " key for rsa-pss-sha512")
+			}
+
+			sum := sha512.Sum512(base)
+
+			err = rsa.VerifyPSS(rsakey, crypto.SHA512, sum[:], sig, &rsa.PSSOptions{
+				SaltLength: rsa.PSSSaltLengthAuto,
+			})
+			return err == nil, nil
+		},
+	},
+}
+
+// parseComponent parses the given component identifier. This is either a
+// component name, or a quoted component name, followed by any parameters, for
+// example "@query-param;name=\"id\"".
+func parseComponent(s string) (sfItem, error) {
+	if !strings.HasPrefix(s, `"`) {
+		name, params := s, ""
+
+		if i := strings.IndexByte(s, ';'); i >= 0 {
+			name, params = s[:i], s[i:]
+		}
+		s = strconv.Quote(strings.ToLower(name)) + params
+	}
+
+	it, err := parseSFItem(s)
+
+	if err != nil {
+		return sfItem{}, errors.New("malformed component " + s)
+	}
+
+	if _, ok := it.val.(string); !ok {
+		return sfItem{}, errors.New("malformed component " + s)
+	}
+	return it, nil
+}
+
+// httpsigQueryParam returns the value of the query parameter with the given
+// name, as described in RFC 9421 section 2.2.8. The parameter must occur once
+// in the query.
+func httpsigQueryParam(r *http.Request, name string) (string, error) {
+	key, err := url.QueryUnescape(name)
+
+	if err != nil {
+		return "", errors.New("malformed query parameter name " + name)
+	}
+
+	vals := r.URL.Query()[key]
+
+	if len(vals) != 1 {
+		if len(vals) == 0 {
+			return "", errors.New("query parameter " + name + " not in request")
+		}
+		return "", errors.New("query parameter " + name + " occurs more than once in request")
+	}
+	return strings.ReplaceAll(url.QueryEscape(vals[0]), "+", "%20"), nil
+}
+
+// httpsigField returns the value of the given header field in the request, as
+// described in RFC 9421 section 2.1. If the sf parameter is set, then the field
+// is re-serialized as a Structured Field. If the key parameter is set, then
+// the field is parsed as a Structured Field dictionary and the value of that
+// member is returned.
+func httpsigField(r *http.Request, comp sfItem) (string, error) {
+	name := comp.val.(string)
+
+	vals := r.Header.Values(name)
+
+	if len(vals) == 0 {
+		if name == "content-length" && r.ContentLength > 0 {
+			vals = []string{strconv.FormatInt(r.ContentLength, 10)}
+		} else {
+			return "", errors.New("component " + name + " not in request")
+		}
+	}
+
+	for i, val := range vals {
+		vals[i] = strings.TrimSpace(val)
+	}
+
+	field := strings.Join(vals, ", ")
+
+	for _, p := range comp.params {
+		if p.key != "sf" && p.key != "key" {
+			return "", errors.New("unsupported parameter " + p.key + " for component " + name)
+		}
+	}
+
+	if val, ok := comp.param("key"); ok {
+		key, ok := val.(string)
+
+		if !ok {
+			return "", errors.New("malformed key parameter for component " + name)
+		}
+
+		members, err := parseSFDict(field)
+
+		if err != nil {
+			return "", errors.New("component " + name + " is not a dictionary")
+		}
+
+		for _, m := range members {
+			if m.key == key {
+				return serializeItem(m.item), nil
+			}
+		}
+		return "", errors.New("key " + key + " not in component " + name)
+	}
+
+	if _, ok := comp.param("sf"); ok {
+		if members, err := parseSFDict(field); err == nil {
+			return serializeSFDict(members), nil
+		}
+
+		items, err := parseSFList(field)
+
+		if err != nil {
+			return "", errors.New("component " + name + " is not a structured field")
+		}
+		return serializeSFList(items), nil
+	}
+	return field, nil
+}
+
+// httpsigComponent returns the value of the given component in the request,
+// as described in RFC 9421 section 2.
+func httpsigComponent(r *http.Request, comp sfItem) (string, error) {
+	name := comp.val.(string)
+
+	if !strings.HasPrefix(name, "@") {
+		return httpsigField(r, comp)
+	}
+
+	if name == "@query-param" {
+		val, ok := comp.param("name")
+
+		if len(comp.params) != 1 || !ok {
+			return "", errors.New("component @query-param requires a name parameter only")
+		}
+
+		param, ok := val.(string)
+
+		if !ok {
+			return "", errors.New("malformed name parameter for component @query-param")
+		}
+		return httpsigQueryParam(r, param)
+	}
+
+	if len(comp.params) > 0 {
+		return "", errors.New("unsupported parameter " + comp.params[0].key + " for component " + name)
+	}
+
+	switch name {
+	case "@method":
+		return r.Method, nil
+	case "@target-uri":
+		return r.URL.String(), nil
+	case "@authority":
+		host := r.Host
+
+		if host == "" {
+			host = r.URL.Host
+		}
+
+		host = strings.ToLower(host)
+
+		if scheme := strings.ToLower(r.URL.Scheme); scheme == "http" {
+			host = strings.TrimSuffix(host, ":80")
+		} else if scheme == "https" {
+			host = strings.TrimSuffix(host, ":443")
+		}
+		return host, nil
+	case "@scheme":
+		return strings.ToLower(r.URL.Scheme), nil
+	case "@request-target":
+		return r.URL.RequestURI(), nil
+	case "@path":
+		path := r.URL.EscapedPath()
+
+		if path == "" {
+			path = "/"
+		}
+		return path, nil
+	case "@query":
+		return "?" + r.URL.RawQuery, nil
+	}
+	return "", errors.New("unsupported component " + name)
+}
+
+// httpsigBase returns the signature base for the given request, as described
+// in RFC 9421 section 2.5. The given params are the serialized signature
+// parameters.
+func httpsigBase(r *http.Request, components []sfItem, params string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	for _, comp := range components {
+		val, err := httpsigComponent(r, comp)
+
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(serializeItem(comp) + ": " + val + "\n")
+	}
+
+	buf.WriteString(`"@signature-params": ` + params)
+	return buf.Bytes(), nil
+}
+
+type httpsigConfig struct {
+	label      string
+	alg        string
+	keyid      string
+	nonce      string
+	tag        string
+	created    int64
+	expires    time.Duration
+	components []sfItem
+	key        value.Value
+}
+
+func newHTTPSigConfig(obj *value.Object) (*httpsigConfig, error) {
+	var (
+		cfg httpsigConfig
+		err error
+	)
+
+	fields := []struct {
+		key string
+		p   *string
+	}{
+		{"Label", &cfg.label},
+		{"Alg", &cfg.alg},
+		{"KeyID", &cfg.keyid},
+		{"Nonce", &cfg.nonce},
+		{"Tag", &cfg.tag},
+	}
+
+	for _, fld := range fields {
+		*fld.p, err = getString(obj, fld.key)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.alg != "" {
+		if _, ok := httpsigAlgs[cfg.alg]; !ok {
+			return nil, errors.New("key error Alg: unsupported algorithm " + cfg.alg)
+		}
+	}
+
+	key, ok := obj.Pairs["Key"]
+
+	if !ok {
+		return nil, errors.New("key error Key: not set")
+	}
+
+	cfg.key = key
+
+	if val, ok := obj.Pairs["Created"]; ok {
+		i, err := value.ToInt(val)
+
+		if err != nil {
+			return nil, errors.New("key error Created: " + err.Error())
+		}
+		cfg.created = i.Value
+	}
+
+	if val, ok := obj.Pairs["Expires"]; ok {
+		d, err := value.ToDuration(val)
+
+		if err != nil {
+			return nil, errors.New("key error Expires: " + err.Error())
+		}
+		cfg.expires = d.Value
+	}
+
+	cfg.components = []sfItem{{val: "@method"}, {val: "@target-uri"}}
+
+	if val, ok := obj.Pairs["Components"]; ok {
+		arr, ok := val.(*value.Array)
+
+		if !ok {
+			return nil, errors.New("key error Components: cannot use " + value.Type(val) + " as array")
+		}
+
+		cfg.components = make([]sfItem, 0, len(arr.Items))
+
+		for _, it := range arr.Items {
+			str, err := value.ToString(it)
+
+			if err != nil {
+				return nil, errors.New("key error Components: " + err.Error())
+			}
+
+			comp, err := parseComponent(str.Value)
+
+			if err != nil {
+				return nil, errors.New("key error Components: " + err.Error())
+			}
+			cfg.components = append(cfg.components, comp)
+		}
+	}
+	return &cfg, nil
+}
+
+// params returns the serialized signature parameters for the configuration.
+func (c *httpsigConfig) params() string {
+	quoted := make([]string, 0, len(c.components))
+
+	for _, comp := range c.components {
+		quoted = append(quoted, serializeItem(comp))
+	}
+
+	created := c.created
+
+	if created == 0 {
+		created = time.Now().Unix()
+	}
+
+	params := "(" + strings.Join(quoted, " ") + ");created=" + strconv.FormatInt(created, 10)
+
+	if c.expires > 0 {
+		params += ";expires=" + strconv.FormatInt(created+int64(c.expires.Seconds()), 10)
+	}
+	if c.keyid != "" {
+		params += ";keyid=" + strconv.Quote(c.keyid)
+	}
+	if c.nonce != "" {
+		params += ";nonce=" + strconv.Quote(c.nonce)
+	}
+	if c.tag != "" {
+		params += ";tag=" + strconv.Quote(c.tag)
+	}
+	return params
+}
+
+// signRequest signs the given request with the given configuration, and sets
+// the Signature-Input and Signature headers. If the content-digest component
+// is covered by the signature and the request does not have the header, then
+// it is set using SHA-256.
+func signRequest(r *http.Request, cfg *httpsigConfig) error {
+	if cfg.alg == "" {
+		return errors.New("key error Alg: not set")
+	}
+
+	for _, comp := range cfg.components {
+		if comp.val == "content-digest" && r.Header.Get("Content-Digest") == "" {
+			digest, err := contentDigest(r, "sha-256")
+
+			if err != nil {
+				return err
+			}
+			r.Header.Set("Content-Digest", digest)
+		}
+	}
+
+	params := cfg.params()
+
+	base, err := httpsigBase(r, cfg.components, params)
+
+	if err != nil {
+		return err
+	}
+
+	sig, err := httpsigAlgs[cfg.alg].sign(cfg.key, base)
+
+	if err != nil {
+		return err
+	}
+
+	label := cfg.label
+
+	if label == "" {
+		label = "sig1"
+	}
+
+	r.Header.Add("Signature-Input", label+"="+params)
+	r.Header.Add("Signature", label+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+	return nil
+}
+
+// httpsigKeyAlg returns the algorithm that the given verification key is for.
+// A PEM encoded key, certificate, or path to one, is for ed25519 or
+// rsa-pss-sha512 depending on the type of the key. Only a secret that is
+// neither is used for hmac-sha256, so a public key cannot be used as the
+// secret for a forged signature.
+func httpsigKeyAlg(val value.Value) (string, error) {
+	b, err := readBytes(val)
+
+	if err != nil {
+		return "", err
+	}
+
+	if !bytes.Contains(b, []byte("-----BEGIN")) {
+		str, ok := val.(value.String)
+
+		if !ok {
+			return "hmac-sha256", nil
+		}
+
+		if _, err := os.Stat(str.Value); err != nil {
+			return "hmac-sha256", nil
+		}
+	}
+
+	key, err := parsePublicKey(val)
+
+	if err != nil {
+		return "", err
+	}
+
+	switch key.(type) {
+	case ed25519.PublicKey:
+		return "ed25519", nil
+	case *rsa.PublicKey:
+		return "rsa-pss-sha512", nil
+	}
+	return "", errors.New("cannot use " + keyType(key) + " key for HTTP message signatures")
+}
+
+// httpsigMember returns the member with the given label in the given header
+// field, which is parsed as a Structured Field dictionary. If no label is
+// given, then the first member is returned.
+func httpsigMember(r *http.Request, field, label string) (sfMember, error) {
+	members, err := parseSFDict(strings.Join(r.Header.Values(field), ", "))
+
+	if err != nil {
+		return sfMember{}, errors.New("malformed " + field + " in request")
+	}
+
+	for _, m := range members {
+		if label == "" || m.key == label {
+			return m, nil
+		}
+	}
+
+	if label == "" {
+		return sfMember{}, errors.New("no " + field + " in request")
+	}
+	return sfMember{}, errors.New("no signature " + label + " in request")
+}
+
+// verifyRequest verifies the signature in the given request with the given
+// configuration. If no label is configured, then the first signature in the
+// request is verified. The algorithm is taken from the key, and the signature
+// is rejected if either the configured algorithm, or the alg parameter of the
+// signature, do not match it.
+func verifyRequest(r *http.Request, cfg *httpsigConfig) (bool, error) {
+	input, err := httpsigMember(r, "Signature-Input", cfg.label)
+
+	if err != nil {
+		return false, err
+	}
+
+	label := input.key
+
+	sig, err := httpsigMember(r, "Signature", label)
+
+	if err != nil {
+		return false, err
+	}
+
+	components, ok := input.item.val.([]sfItem)
+
+	if !ok {
+		return false, errors.New("malformed signature input " + label)
+	}
+
+	for _, comp := range components {
+		if _, ok := comp.val.(string); !ok {
+			return false, errors.New("malformed signature input " + label)
+		}
+	}
+
+	b, ok := sig.item.val.([]byte)
+
+	if !ok {
+		return false, errors.New("malformed signature " + label)
+	}
+
+	params := make(map[string]interface{})
+
+	for _, p := range input.item.params {
+		params[p.key] = p.val
+	}
+
+	if val, ok := params["expires"]; ok {
+		expires, ok := val.(int64)
+
+		if !ok {
+			return false, errors.New("malformed expires in signature input")
+		}
+
+		if time.Now().Unix() > expires {
+			return false, nil
+		}
+	}
+
+	alg, err := httpsigKeyAlg(cfg.key)
+
+	if err != nil {
+		return false, err
+	}
+
+	if cfg.alg != "" && cfg.alg != alg {
+		return false, errors.New("algorithm " + cfg.alg + " does not match key")
+	}
+
+	if val, ok := params["alg"]; ok {
+		if s, _ := val.(string); s != alg {
+			return false, errors.New("signature algorithm " + serializeBareItem(val) + " does not match key")
+		}
+	}
+
+	for _, comp := range components {
+		if comp.val != "content-digest" {
+			continue
+		}
+
+		hdr := r.Header.Get("Content-Digest")
+
+		alg := hdr
+
+		if i := strings.IndexByte(hdr, '='); i >= 0 {
+			alg = hdr[:i]
+		}
+
+		digest, err := contentDigest(r, alg)
+
+		if err != nil {
+			return false, err
+		}
+
+		if digest != hdr {
+			return false, nil
+		}
+	}
+
+	base, err := httpsigBase(r, components, serializeItem(input.item))
+
+	if err != nil {
+		return false, err
+	}
+	return httpsigAlgs[alg].verify(cfg.key, base, b)
+}
+
+func getHTTPSigArgs(args []value.Value) (*httpsigConfig, *value.Request, error) {
+	obj, err := value.ToObject(args[0])
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := value.ToRequest(args[1])
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := newHTTPSigConfig(obj)
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, req, nil
+}
+
+func signHTTPSig(cmd string, args []value.Value) (value.Value, error) {
+	cfg, req, err := getHTTPSigArgs(args)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	if err := signRequest(req.Request, cfg); err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return req, nil
+}
+
+func verifyHTTPSig(cmd string, args []value.Value) (value.Value, error) {
+	cfg, req, err := getHTTPSigArgs(args)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	ok, err := verifyRequest(req.Request, cfg)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return value.Bool{Value: ok}, nil
+}
+
+// webhookConfig is the configuration for signing webhook payloads with an
+// HMAC. The defaults for this configuration match that of GitHub's webhooks.
+type webhookConfig struct {
+	key      []byte
+	alg      string
+	header   string
+	prefix   string
+	encoding string
+}
+
+func newWebhookConfig(obj *value.Object) (*webhookConfig, error) {
+	var (
+		cfg webhookConfig
+		err error
+	)
+
+	key, ok := obj.Pairs["Key"]
+
+	if !ok {
+		return nil, errors.New("key error Key: not set")
+	}
+
+	cfg.key, err = readBytes(key)
+
+	if err != nil {
+		return nil, errors.New("key error Key: " + err.Error())
+	}
+
+	fields := []struct {
+		key  string
+		p    *string
+		def  string
+		tab  map[string]struct{}
+		desc string
+	}{
+		{"Alg", &cfg.alg, "sha256", nil, ""},
+		{"Header", &cfg.header, "X-Hub-Signature-256", nil, ""},
+		{"Encoding", &cfg.encoding, "hex", map[string]struct{}{"hex": {}, "base64": {}}, "encoding"},
+	}
+
+	for _, fld := range fields {
+		*fld.p, err = getString(obj, fld.key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if *fld.p == "" {
+			*fld.p = fld.def
+		}
+
+		if fld.tab != nil {
+			if _, ok := fld.tab[*fld.p]; !ok {
+				return nil, errors.New("key error " + fld.key + ": unsupported " + fld.desc + " " + *fld.p)
+			}
+		}
+	}
+
+	if _, ok := hashtab[cfg.alg]; !ok {
+		return nil, errors.New("key error Alg: unsupported algorithm " + cfg.alg)
+	}
+
+	cfg.prefix = cfg.alg + "="
+
+	if _, ok := obj.Pairs["Prefix"]; ok {
+		cfg.prefix, err = getString(obj, "Prefix")
+
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &cfg, nil
+}
+
+// signature returns the signature of the given request's body, without the
+// configured prefix.
+func (c *webhookConfig) signature(r *http.Request) (string, error) {
+	b, err := requestBody(r)
+
+	if err != nil {
+		return "", err
+	}
+
+	h := hmac.New(hashtab[c.alg], c.key)
+	h.Write(b)
+
+	if c.encoding == "base64" {
+		return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func getWebhookArgs(args []value.Value) (*webhookConfig, *value.Request, error) {
+	obj, err := value.ToObject(args[0])
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := value.ToRequest(args[1])
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := newWebhookConfig(obj)
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, req, nil
+}
+
+func signWebhook(cmd string, args []value.Value) (value.Value, error) {
+	cfg, req, err := getWebhookArgs(args)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	sig, err := cfg.signature(req.Request)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	req.Header.Set(cfg.header, cfg.prefix+sig)
+	return req, nil
+}
+
+func verifyWebhook(cmd string, args []value.Value) (value.Value, error) {
+	cfg, req, err := getWebhookArgs(args)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	sig, err := cfg.signature(req.Request)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	hdr := req.Header.Get(cfg.header)
+
+	if !strings.HasPrefix(hdr, cfg.prefix) {
+		return value.Bool{}, nil
+	}
+
+	return value.Bool{
+		Value: hmac.Equal([]byte(hdr[len(cfg.prefix):]), []byte(sig)),
+	}, nil
+}
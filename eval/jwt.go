@@ -0,0 +1,573 @@
+package eval
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/andrewpillar/req/value"
+)
+
+// jwtAlg is a signing algorithm for a JSON Web Token, as described in RFC 7518
+// section 3, and RFC 8037 section 3.1 for EdDSA.
+type jwtAlg struct {
+	sign   func(key value.Value, data []byte) ([]byte, error)
+	verify func(key interface{}, data, sig []byte) bool
+}
+
+var jwtAlgs = map[string]jwtAlg{
+	"HS256": {
+		sign: func(key value.Value, data []byte) ([]byte, error) {
+			secret, err := readBytes(key)
+
+			if err != nil {
+				return nil, err
+			}
+
+			h := hmac.New(sha256.New, secret)
+			h.Write(data)
+
+			return h.Sum(nil), nil
+		},
+		verify: func(key interface{}, data, sig []byte) bool {
+			secret, ok := key.([]byte)
+
+			if !ok {
+				return false
+			}
+
+			h := hmac.New(sha256.New, secret)
+			h.Write(data)
+
+			return hmac.Equal(h.Sum(nil), sig)
+		},
+	},
+	"RS256": {
+		sign: func(key value.Value, data []byte) ([]byte, error) {
+			priv, err := parsePrivateKey(key)
+
+			if err != nil {
+				return nil, err
+			}
+
+			rsakey, ok := priv.(*rsa.PrivateKey)
+
+			if !ok {
+				return nil, errors.New("cannot use " + keyType(priv) + " key for RS256")
+			}
+
+			sum := sha256.Sum256(data)
+
+			return rsa.SignPKCS1v15(rand.Reader, rsakey, crypto.SHA256, sum[:])
+		},
+		verify: func(key interface{}, data, sig []byte) bool {
+			rsakey, ok := key.(*rsa.PublicKey)
+
+			if !ok {
+				return false
+			}
+
+			sum := sha256.Sum256(data)
+
+			return rsa.VerifyPKCS1v15(rsakey, crypto.SHA256, sum[:], sig) == nil
+		},
+	},
+	"ES256": {
+		sign: func(key value.Value, data []byte) ([]byte, error) {
+			priv, err := parsePrivateKey(key)
+
+			if err != nil {
+				return nil, err
+			}
+
+			eckey, ok := priv.(*ecdsa.PrivateKey)
+
+			if !ok || eckey.Curve != elliptic.P256() {
+				return nil, errors.New("cannot use " + keyType(priv) + " key for ES256")
+			}
+
+			sum := sha256.Sum256(data)
+
+			r, s, err := ecdsa.Sign(rand.Reader, eckey, sum[:])
+
+			if err != nil {
+				return nil, err
+			}
+
+			// The signature is the concatenation of R and S, each padded to
+			// the size of the curve, rather than ASN.1.
+			sig := make([]byte, 64)
+
+			r.FillBytes(sig[:32])
+			s.FillBytes(sig[32:])
+
+			return sig, nil
+		},
+		verify: func(key interface{}, data, sig []byte) bool {
+			eckey, ok := key.(*ecdsa.PublicKey)
+
+			if !ok || len(sig) != 64 {
+				return false
+			}
+
+			sum := sha256.Sum256(data)
+
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+
+			return ecdsa.Verify(eckey, sum[:], r, s)
+		},
+	},
+	"EdDSA": {
+		sign: func(key value.Value, data []byte) ([]byte, error) {
+			priv, err := parsePrivateKey(key)
+
+			if err != nil {
+				return nil, err
+			}
+
+			edkey, ok := priv.(ed25519.PrivateKey)
+
+			if !ok {
+				return nil, errors.New("cannot use " + keyType(priv) + " key for EdDSA")
+			}
+			return ed25519.Sign(edkey, data), nil
+		},
+		verify: func(key interface{}, data, sig []byte) bool {
+			edkey, ok := key.(ed25519.PublicKey)
+
+			if !ok {
+				return false
+			}
+			return ed25519.Verify(edkey, data, sig)
+		},
+	},
+}
+
+// jwtTimeClaims are the registered claims that are NumericDate values. These
+// are decoded to Time values.
+var jwtTimeClaims = map[string]struct{}{
+	"exp": {},
+	"iat": {},
+	"nbf": {},
+}
+
+var b64url = base64.RawURLEncoding
+
+// jwtJSON encodes the given object to JSON for use in a JSON Web Token. Any
+// Time values in the object are encoded as NumericDate values.
+func jwtJSON(obj *value.Object) ([]byte, error) {
+	pairs := make(map[string]value.Value, len(obj.Pairs))
+
+	for k, v := range obj.Pairs {
+		if t, ok := v.(value.Time); ok {
+			v = value.Int{Value: t.Value.Unix()}
+		}
+		pairs[k] = v
+	}
+	return json.Marshal(&value.Object{Pairs: pairs})
+}
+
+func encodeJwt(cmd string, args []value.Value) (value.Value, error) {
+	if l := len(args); l < 3 || l > 4 {
+		err := errNotEnoughArgs
+
+		if l > 4 {
+			err = errTooManyArgs
+		}
+
+		return nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	name, err := value.ToName(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	alg, ok := jwtAlgs[name.Value]
+
+	if !ok {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("unsupported algorithm " + name.Value),
+		}
+	}
+
+	hdr := &value.Object{
+		Pairs: make(map[string]value.Value),
+	}
+
+	if len(args) > 3 {
+		obj, err := value.ToObject(args[2])
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		for k, v := range obj.Pairs {
+			hdr.Pairs[k] = v
+		}
+	}
+
+	hdr.Pairs["alg"] = value.String{Value: name.Value}
+
+	if _, ok := hdr.Pairs["typ"]; !ok {
+		hdr.Pairs["typ"] = value.String{Value: "JWT"}
+	}
+
+	claims, err := value.ToObject(args[len(args)-1])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	parts := make([]string, 0, 3)
+
+	for _, obj := range []*value.Object{hdr, claims} {
+		b, err := jwtJSON(obj)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+		parts = append(parts, b64url.EncodeToString(b))
+	}
+
+	sig, err := alg.sign(args[1], []byte(strings.Join(parts, ".")))
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	parts = append(parts, b64url.EncodeToString(sig))
+
+	return value.String{
+		Value: strings.Join(parts, "."),
+	}, nil
+}
+
+// jwk is a JSON Web Key, as described in RFC 7517. Only the fields required
+// for verification are decoded.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *jwk) key() (interface{}, error) {
+	fields := make(map[string][]byte)
+
+	for name, s := range map[string]string{"n": k.N, "e": k.E, "x": k.X, "y": k.Y} {
+		b, err := b64url.DecodeString(strings.TrimRight(s, "="))
+
+		if err != nil {
+			return nil, errors.New("malformed " + name + " in JWK")
+		}
+		fields[name] = b
+	}
+
+	switch k.Kty {
+	case "RSA":
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(fields["n"]),
+			E: int(new(big.Int).SetBytes(fields["e"]).Int64()),
+		}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, errors.New("unsupported curve " + k.Crv + " in JWK")
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(fields["x"]),
+			Y:     new(big.Int).SetBytes(fields["y"]),
+		}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, errors.New("unsupported curve " + k.Crv + " in JWK")
+		}
+		return ed25519.PublicKey(fields["x"]), nil
+	default:
+		return nil, errors.New("unsupported key type " + k.Kty + " in JWK")
+	}
+}
+
+// jwtKey returns the key for verifying a token with the given header. The key
+// can be a secret for HS256, a PEM encoded public key, certificate, or private
+// key, or a JWK Set. The latter two can be given as a path to a file. When a
+// JWK Set is given, the key is selected by the kid in the header if any. Only
+// a string that is neither of the latter two is used as a secret, so a public
+// key cannot be used as the secret for a forged HS256 token.
+func jwtKey(val value.Value, alg, kid string) (interface{}, error) {
+	b, err := readBytes(val)
+
+	if err != nil {
+		return nil, err
+	}
+
+	isKey := func(b []byte) bool {
+		return bytes.Contains(b, []byte("-----BEGIN")) || bytes.HasPrefix(bytes.TrimSpace(b), []byte("{"))
+	}
+
+	if _, ok := val.(value.String); ok && !isKey(b) {
+		if _, err := os.Stat(string(b)); err != nil {
+			return b, nil
+		}
+
+		b, err = os.ReadFile(string(b))
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		return parsePublicKey(value.String{Value: string(b)})
+	}
+
+	var set struct {
+		Keys []*jwk `json:"keys"`
+	}
+
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, err
+	}
+
+	// A single JWK was given rather than a set.
+	if set.Keys == nil {
+		var k jwk
+
+		if err := json.Unmarshal(b, &k); err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, &k)
+	}
+
+	for _, k := range set.Keys {
+		if kid != "" && k.Kid != "" && k.Kid != kid {
+			continue
+		}
+
+		if k.Alg != "" && k.Alg != alg {
+			continue
+		}
+
+		key, err := k.key()
+
+		if err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+
+	if kid != "" {
+		return nil, errors.New("no key " + kid + " in JWK set")
+	}
+	return nil, errors.New("no key for " + alg + " in JWK set")
+}
+
+// jwtKeyAlg returns the algorithm that the given verification key is for.
+func jwtKeyAlg(key interface{}) string {
+	switch k := key.(type) {
+	case []byte:
+		return "HS256"
+	case *rsa.PublicKey:
+		return "RS256"
+	case *ecdsa.PublicKey:
+		if k.Curve == elliptic.P256() {
+			return "ES256"
+		}
+	case ed25519.PublicKey:
+		return "EdDSA"
+	}
+	return ""
+}
+
+// decodeJwtPart decodes the given base64url encoded JSON object of a token.
+func decodeJwtPart(s string) (*value.Object, error) {
+	b, err := b64url.DecodeString(strings.TrimRight(s, "="))
+
+	if err != nil {
+		return nil, errors.New("malformed token")
+	}
+
+	val, err := value.DecodeJSON(bytes.NewReader(b))
+
+	if err != nil {
+		return nil, errors.New("malformed token")
+	}
+
+	obj, ok := val.(*value.Object)
+
+	if !ok {
+		return nil, errors.New("malformed token")
+	}
+	return obj, nil
+}
+
+func decodeJwt(cmd string, args []value.Value) (value.Value, error) {
+	if l := len(args); l < 1 || l > 2 {
+		err := errNotEnoughArgs
+
+		if l > 2 {
+			err = errTooManyArgs
+		}
+
+		return nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	str, err := value.ToString(args[len(args)-1])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	tok := strings.TrimSpace(str.Value)
+	tok = strings.TrimPrefix(tok, "Bearer ")
+
+	parts := strings.Split(tok, ".")
+
+	if len(parts) != 3 {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("malformed token"),
+		}
+	}
+
+	hdr, err := decodeJwtPart(parts[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	claims, err := decodeJwtPart(parts[1])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	for k := range jwtTimeClaims {
+		var secs int64
+
+		switch v := claims.Pairs[k].(type) {
+		case value.Int:
+			secs = v.Value
+		case value.Float:
+			secs = int64(v.Value)
+		default:
+			continue
+		}
+		claims.Pairs[k] = value.Time{Value: time.Unix(secs, 0)}
+	}
+
+	// No key was given, so the token is decoded without being verified.
+	if len(args) == 1 {
+		return claims, nil
+	}
+
+	name, _ := hdr.Pairs["alg"].(value.String)
+	kid, _ := hdr.Pairs["kid"].(value.String)
+
+	alg, ok := jwtAlgs[name.Value]
+
+	if !ok {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("unsupported algorithm " + name.Value),
+		}
+	}
+
+	key, err := jwtKey(args[0], name.Value, kid.Value)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	// The algorithm in the header cannot be trusted, so it must be the one
+	// the key is for.
+	if jwtKeyAlg(key) != name.Value {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("token algorithm " + name.Value + " does not match key"),
+		}
+	}
+
+	sig, err := b64url.DecodeString(strings.TrimRight(parts[2], "="))
+
+	if err != nil || !alg.verify(key, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("invalid token signature"),
+		}
+	}
+
+	now := time.Now()
+
+	if exp, ok := claims.Pairs["exp"].(value.Time); ok && !now.Before(exp.Value) {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("token expired at " + exp.String()),
+		}
+	}
+
+	if nbf, ok := claims.Pairs["nbf"].(value.Time); ok && now.Before(nbf.Value) {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("token not valid until " + nbf.String()),
+		}
+	}
+	return claims, nil
+}
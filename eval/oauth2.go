@@ -0,0 +1,466 @@
+package eval
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewpillar/req/value"
+)
+
+// OAuth2Cmd implements the oauth2 command for obtaining an access token from
+// a token endpoint, and attaching it to a request.
+var OAuth2Cmd = &Command{
+	Name: "oauth2",
+	Argc: -1,
+	Func: oauth2,
+}
+
+// oauth2Token is an access token obtained from a token endpoint. This is also
+// the format in which tokens are cached to disk.
+type oauth2Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+}
+
+// oauth2ExpiryDelta is how long before the actual expiry of a token it is
+// considered as expired. This avoids using a token that would expire whilst
+// a request is in flight.
+const oauth2ExpiryDelta = 10 * time.Second
+
+func (t *oauth2Token) valid() bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+
+	if t.Expiry.IsZero() {
+		return true
+	}
+	return time.Now().Add(oauth2ExpiryDelta).Before(t.Expiry)
+}
+
+// header returns the value of the Authorization header for the token.
+func (t *oauth2Token) header() string {
+	typ := t.TokenType
+
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer"
+	}
+	return typ + " " + t.AccessToken
+}
+
+// oauth2Config is the configuration given to the oauth2 command for obtaining
+// an access token.
+type oauth2Config struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	grant        string
+	username     string
+	password     string
+	refreshToken string
+	scope        string
+	cache        string
+}
+
+var (
+	oauth2Mu     sync.Mutex
+	oauth2Tokens = make(map[string]*oauth2Token)
+)
+
+func newOAuth2Config(obj *value.Object) (*oauth2Config, error) {
+	var (
+		cfg oauth2Config
+		err error
+	)
+
+	fields := []struct {
+		key string
+		p   *string
+	}{
+		{"TokenURL", &cfg.tokenURL},
+		{"ClientID", &cfg.clientID},
+		{"ClientSecret", &cfg.clientSecret},
+		{"Grant", &cfg.grant},
+		{"Username", &cfg.username},
+		{"Password", &cfg.password},
+		{"RefreshToken", &cfg.refreshToken},
+		{"Cache", &cfg.cache},
+	}
+
+	for _, fld := range fields {
+		*fld.p, err = getString(obj, fld.key)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.tokenURL == "" {
+		return nil, errors.New("key error TokenURL: token endpoint not set")
+	}
+
+	switch v := obj.Pairs["Scope"].(type) {
+	case nil:
+	case value.String:
+		cfg.scope = v.Value
+	case *value.Array:
+		scopes := make([]string, 0, len(v.Items))
+
+		for _, it := range v.Items {
+			str, err := value.ToString(it)
+
+			if err != nil {
+				return nil, errors.New("key error Scope: " + err.Error())
+			}
+			scopes = append(scopes, str.Value)
+		}
+		cfg.scope = strings.Join(scopes, " ")
+	default:
+		return nil, errors.New("key error Scope: cannot use " + value.Type(v) + " as scope")
+	}
+
+	switch cfg.grant {
+	case "":
+		cfg.grant = "client_credentials"
+	case "client_credentials":
+	case "password":
+		if cfg.username == "" {
+			return nil, errors.New("key error Username: username not set for password grant")
+		}
+	case "refresh_token":
+		if cfg.refreshToken == "" {
+			return nil, errors.New("key error RefreshToken: refresh token not set for refresh_token grant")
+		}
+	default:
+		return nil, errors.New("key error Grant: unsupported grant " + cfg.grant)
+	}
+	return &cfg, nil
+}
+
+// key returns the key under which tokens for the configuration are held in
+// memory.
+func (c *oauth2Config) key() string {
+	return strings.Join([]string{c.tokenURL, c.clientID, c.grant, c.username, c.scope, c.cache}, "\x00")
+}
+
+func (c *oauth2Config) readCache() *oauth2Token {
+	b, err := os.ReadFile(c.cache)
+
+	if err != nil {
+		return nil
+	}
+
+	var tok oauth2Token
+
+	if err := json.Unmarshal(b, &tok); err != nil {
+		return nil
+	}
+	return &tok
+}
+
+func (c *oauth2Config) writeCache(tok *oauth2Token) error {
+	b, err := json.Marshal(tok)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.cache), os.FileMode(0700)); err != nil {
+		return err
+	}
+	return os.WriteFile(c.cache, b, os.FileMode(0600))
+}
+
+// fetch requests a new token from the token endpoint using the given
+// parameters.
+func (c *oauth2Config) fetch(rt http.RoundTripper, params url.Values) (*oauth2Token, error) {
+	if c.scope != "" {
+		params.Set("scope", c.scope)
+	}
+
+	if c.clientSecret == "" && c.clientID != "" {
+		params.Set("client_id", c.clientID)
+	}
+
+	req, err := http.NewRequest("POST", c.tokenURL, strings.NewReader(params.Encode()))
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if c.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+	}
+
+	cli := http.Client{
+		Transport: rt,
+	}
+
+	resp, err := cli.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var body struct {
+		AccessToken  string      `json:"access_token"`
+		TokenType    string      `json:"token_type"`
+		RefreshToken string      `json:"refresh_token"`
+		ExpiresIn    json.Number `json:"expires_in"`
+		Error        string      `json:"error"`
+		Description  string      `json:"error_description"`
+	}
+
+	typ, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	if typ == "application/x-www-form-urlencoded" || typ == "text/plain" {
+		vals, err := url.ParseQuery(string(b))
+
+		if err != nil {
+			return nil, err
+		}
+
+		body.AccessToken = vals.Get("access_token")
+		body.TokenType = vals.Get("token_type")
+		body.RefreshToken = vals.Get("refresh_token")
+		body.ExpiresIn = json.Number(vals.Get("expires_in"))
+		body.Error = vals.Get("error")
+		body.Description = vals.Get("error_description")
+	} else {
+		if err := json.Unmarshal(b, &body); err != nil {
+			if resp.StatusCode != http.StatusOK {
+				return nil, errors.New("token endpoint responded with " + resp.Status)
+			}
+			return nil, err
+		}
+	}
+
+	if body.Error != "" {
+		msg := body.Error
+
+		if body.Description != "" {
+			msg += ": " + body.Description
+		}
+		return nil, errors.New("token endpoint responded with error " + msg)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("token endpoint responded with " + resp.Status)
+	}
+
+	if body.AccessToken == "" {
+		return nil, errors.New("no access token in token endpoint response")
+	}
+
+	tok := &oauth2Token{
+		AccessToken:  body.AccessToken,
+		TokenType:    body.TokenType,
+		RefreshToken: body.RefreshToken,
+	}
+
+	if body.ExpiresIn != "" {
+		secs, err := strconv.ParseInt(string(body.ExpiresIn), 10, 64)
+
+		if err != nil {
+			return nil, errors.New("invalid expires_in in token endpoint response")
+		}
+
+		if secs > 0 {
+			tok.Expiry = time.Now().Add(time.Duration(secs) * time.Second)
+		}
+	}
+	return tok, nil
+}
+
+// token returns a valid token for the configuration. This will first check
+// the tokens held in memory, then the cache file if configured. If the token
+// that is found has expired, then it is refreshed if possible, otherwise a
+// new token is requested via the configured grant.
+func (c *oauth2Config) token(rt http.RoundTripper) (*oauth2Token, error) {
+	oauth2Mu.Lock()
+	defer oauth2Mu.Unlock()
+
+	key := c.key()
+
+	tok := oauth2Tokens[key]
+
+	if tok == nil && c.cache != "" {
+		tok = c.readCache()
+	}
+
+	if tok.valid() {
+		oauth2Tokens[key] = tok
+		return tok, nil
+	}
+
+	var (
+		next *oauth2Token
+		err  error
+	)
+
+	if tok != nil && tok.RefreshToken != "" {
+		next, err = c.fetch(rt, url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {tok.RefreshToken},
+		})
+
+		// The refresh token may not be sent back, in which case we keep
+		// using the one we have.
+		if err == nil && next.RefreshToken == "" {
+			next.RefreshToken = tok.RefreshToken
+		}
+	}
+
+	if next == nil {
+		params := url.Values{
+			"grant_type": {c.grant},
+		}
+
+		switch c.grant {
+		case "password":
+			params.Set("username", c.username)
+			params.Set("password", c.password)
+		case "refresh_token":
+			params.Set("refresh_token", c.refreshToken)
+		}
+
+		next, err = c.fetch(rt, params)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	oauth2Tokens[key] = next
+
+	if c.cache != "" {
+		if err := c.writeCache(next); err != nil {
+			return nil, err
+		}
+	}
+	return next, nil
+}
+
+// oauth2Transport returns middleware that sets the Authorization header of
+// the request to a token for the given configuration. The token is obtained
+// using the Transport of the given request, so that any TLS configuration for
+// the request is also used when talking to the token endpoint.
+func oauth2Transport(cfg *oauth2Config, req *value.Request) func(http.RoundTripper) http.RoundTripper {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			rt := req.Transport
+
+			if rt == nil {
+				rt = http.DefaultTransport
+			}
+
+			tok, err := cfg.token(rt)
+
+			if err != nil {
+				return nil, errors.New("oauth2: " + err.Error())
+			}
+
+			r = r.Clone(r.Context())
+			r.Header.Set("Authorization", tok.header())
+
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+func oauth2(cmd string, args []value.Value) (value.Value, error) {
+	if l := len(args); l < 1 || l > 2 {
+		err := errNotEnoughArgs
+
+		if l > 2 {
+			err = errTooManyArgs
+		}
+
+		return nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	obj, err := value.ToObject(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	cfg, err := newOAuth2Config(obj)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	// No request was given, so return the token itself.
+	if len(args) == 1 {
+		tok, err := cfg.token(http.DefaultTransport)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		var expiry value.Value = value.Zero{}
+
+		if !tok.Expiry.IsZero() {
+			expiry = value.Time{Value: tok.Expiry}
+		}
+
+		return &value.Object{
+			Order: []string{"AccessToken", "TokenType", "RefreshToken", "Expiry"},
+			Pairs: map[string]value.Value{
+				"AccessToken":  value.String{Value: tok.AccessToken},
+				"TokenType":    value.String{Value: tok.TokenType},
+				"RefreshToken": value.String{Value: tok.RefreshToken},
+				"Expiry":       expiry,
+			},
+		}, nil
+	}
+
+	req, err := value.ToRequest(args[1])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	req.Use(oauth2Transport(cfg, req))
+	return req, nil
+}
@@ -0,0 +1,152 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/andrewpillar/req/value"
+)
+
+// PrettyCmd implements the pretty command for pretty printing JSON and XML.
+// The format is detected from the Content-Type of a response, otherwise from
+// the content itself.
+var PrettyCmd = &Command{
+	Name: "pretty",
+	Argc: 1,
+	Func: pretty,
+}
+
+// prettyIndent is the indentation used when pretty printing.
+const prettyIndent = "  "
+
+func prettyJSON(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := json.Indent(&buf, b, "", prettyIndent); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// prettyXML re-encodes the given XML with indentation. Whitespace between
+// elements is dropped, and namespace prefixes are kept as they are.
+func prettyXML(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", prettyIndent)
+
+	// Encode prefixed names as is, otherwise the encoder will treat the
+	// prefix as a namespace.
+	rawName := func(name xml.Name) xml.Name {
+		if name.Space == "" {
+			return name
+		}
+		return xml.Name{Local: name.Space + ":" + name.Local}
+	}
+
+	for {
+		tok, err := dec.RawToken()
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		switch v := tok.(type) {
+		case xml.StartElement:
+			v.Name = rawName(v.Name)
+
+			attrs := make([]xml.Attr, 0, len(v.Attr))
+
+			for _, a := range v.Attr {
+				a.Name = rawName(a.Name)
+				attrs = append(attrs, a)
+			}
+
+			v.Attr = attrs
+			tok = v
+		case xml.EndElement:
+			v.Name = rawName(v.Name)
+			tok = v
+		case xml.CharData:
+			if len(bytes.TrimSpace(v)) == 0 {
+				continue
+			}
+		}
+
+		if err := enc.EncodeToken(xml.CopyToken(tok)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pretty pretty prints the JSON or XML in the given response, stream, or
+// string. If the content is neither JSON nor XML, or cannot be parsed, then it
+// is returned as is.
+func pretty(cmd string, args []value.Value) (value.Value, error) {
+	var (
+		typ string
+		val value.Value = args[0]
+	)
+
+	if resp, ok := val.(value.Response); ok {
+		typ = resp.Header.Get("Content-Type")
+
+		body, err := resp.Select(value.Name{Value: "Body"})
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+		val = body
+	}
+
+	b, err := readBytes(val)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	if typ == "" {
+		switch trimmed := bytes.TrimSpace(b); {
+		case bytes.HasPrefix(trimmed, []byte("{")), bytes.HasPrefix(trimmed, []byte("[")):
+			typ = "json"
+		case bytes.HasPrefix(trimmed, []byte("<")):
+			typ = "xml"
+		}
+	}
+
+	var fn func([]byte) ([]byte, error)
+
+	switch {
+	case strings.Contains(typ, "json"):
+		fn = prettyJSON
+	case strings.Contains(typ, "xml"):
+		fn = prettyXML
+	}
+
+	if fn != nil {
+		if pretty, err := fn(b); err == nil {
+			b = pretty
+		}
+	}
+	return value.String{Value: string(b)}, nil
+}
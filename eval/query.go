@@ -0,0 +1,662 @@
+package eval
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/andrewpillar/req/syntax"
+	"github.com/andrewpillar/req/value"
+)
+
+// QueryCmd implements the query command for querying values with a JSONPath
+// expression. This supports a subset of JSONPath, namely field access,
+// indexing, slicing, wildcards, filters, and recursive descent.
+var QueryCmd = &Command{
+	Name: "query",
+	Argc: 2,
+	Func: query,
+}
+
+type queryStepKind uint
+
+const (
+	childStep queryStepKind = iota + 1
+	wildcardStep
+	indexStep
+	sliceStep
+	filterStep
+)
+
+// queryStep is a single step in a query. Each step is applied to the values
+// matched by the previous step. If descend is true, then the step is applied
+// to each of those values and all of their descendants.
+type queryStep struct {
+	kind    queryStepKind
+	descend bool
+	name    string
+	index   int
+	start   *int
+	end     *int
+	filter  queryExpr
+}
+
+// queryExpr is an expression within a filter step. The @ value is the item
+// being filtered.
+type queryExpr interface {
+	eval(at value.Value) value.Value
+}
+
+// queryPath is a relative path within a filter, such as @.status.
+type queryPath []*queryStep
+
+// queryLit is a literal value within a filter.
+type queryLit struct {
+	val value.Value
+}
+
+// queryNot negates the truthiness of its operand.
+type queryNot struct {
+	x queryExpr
+}
+
+// queryOp is a comparison, or logical operation within a filter.
+type queryOp struct {
+	op          string
+	left, right queryExpr
+}
+
+// parsedQuery is a parsed query expression. A query is definite if it can
+// only ever match a single value.
+type parsedQuery struct {
+	steps    []*queryStep
+	definite bool
+}
+
+type queryParser struct {
+	s   string
+	pos int
+}
+
+func (p *queryParser) err(msg string) error {
+	return errors.New("invalid query at offset " + strconv.Itoa(p.pos) + ": " + msg)
+}
+
+func (p *queryParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *queryParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *queryParser) skipSpace() {
+	for !p.eof() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *queryParser) got(s string) bool {
+	if strings.HasPrefix(p.s[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func isQueryNameByte(b byte) bool {
+	return b == '_' || b == '-' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+}
+
+func (p *queryParser) name() (string, error) {
+	start := p.pos
+
+	for !p.eof() && isQueryNameByte(p.s[p.pos]) {
+		p.pos++
+	}
+
+	if start == p.pos {
+		return "", p.err("expected name")
+	}
+	return p.s[start:p.pos], nil
+}
+
+func (p *queryParser) str() (string, error) {
+	quote := p.peek()
+	p.pos++
+
+	var buf strings.Builder
+
+	for !p.eof() {
+		b := p.s[p.pos]
+		p.pos++
+
+		if b == '\\' && !p.eof() {
+			buf.WriteByte(p.s[p.pos])
+			p.pos++
+			continue
+		}
+
+		if b == quote {
+			return buf.String(), nil
+		}
+		buf.WriteByte(b)
+	}
+	return "", p.err("unterminated string")
+}
+
+func (p *queryParser) int() (int, error) {
+	start := p.pos
+
+	if p.peek() == '-' {
+		p.pos++
+	}
+
+	for !p.eof() && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+
+	i, err := strconv.Atoi(p.s[start:p.pos])
+
+	if err != nil {
+		p.pos = start
+		return 0, p.err("expected integer")
+	}
+	return i, nil
+}
+
+// steps parses the steps in a path until either the end of the query is
+// reached, or a byte that cannot start a step is found.
+func (p *queryParser) steps(inFilter bool) ([]*queryStep, error) {
+	steps := make([]*queryStep, 0)
+
+	for !p.eof() {
+		descend := false
+
+		switch {
+		case p.got(".."):
+			descend = true
+		case p.got("."):
+		case p.peek() == '[':
+		default:
+			if inFilter {
+				return steps, nil
+			}
+			return nil, p.err("unexpected " + strconv.QuoteRune(rune(p.peek())))
+		}
+
+		var (
+			step *queryStep
+			err  error
+		)
+
+		switch {
+		case p.peek() == '[':
+			step, err = p.bracket()
+		case p.got("*"):
+			step = &queryStep{kind: wildcardStep}
+		default:
+			var name string
+
+			name, err = p.name()
+			step = &queryStep{kind: childStep, name: name}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		step.descend = descend
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func (p *queryParser) bracket() (*queryStep, error) {
+	p.pos++
+	p.skipSpace()
+
+	var step *queryStep
+
+	switch b := p.peek(); {
+	case b == '*':
+		p.pos++
+		step = &queryStep{kind: wildcardStep}
+	case b == '\'' || b == '"':
+		name, err := p.str()
+
+		if err != nil {
+			return nil, err
+		}
+		step = &queryStep{kind: childStep, name: name}
+	case b == '?':
+		p.pos++
+		p.skipSpace()
+
+		paren := p.got("(")
+
+		x, err := p.or()
+
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpace()
+
+		if paren && !p.got(")") {
+			return nil, p.err("expected )")
+		}
+		step = &queryStep{kind: filterStep, filter: x}
+	default:
+		step = &queryStep{kind: indexStep}
+
+		if b != ':' {
+			i, err := p.int()
+
+			if err != nil {
+				return nil, err
+			}
+
+			step.index = i
+			step.start = &i
+		}
+
+		p.skipSpace()
+
+		if p.got(":") {
+			step.kind = sliceStep
+
+			p.skipSpace()
+
+			if p.peek() != ']' {
+				i, err := p.int()
+
+				if err != nil {
+					return nil, err
+				}
+				step.end = &i
+			}
+		}
+	}
+
+	p.skipSpace()
+
+	if !p.got("]") {
+		return nil, p.err("expected ]")
+	}
+	return step, nil
+}
+
+func (p *queryParser) or() (queryExpr, error) {
+	x, err := p.and()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+
+		if !p.got("||") {
+			return x, nil
+		}
+
+		y, err := p.and()
+
+		if err != nil {
+			return nil, err
+		}
+		x = &queryOp{op: "||", left: x, right: y}
+	}
+}
+
+func (p *queryParser) and() (queryExpr, error) {
+	x, err := p.cmp()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+
+		if !p.got("&&") {
+			return x, nil
+		}
+
+		y, err := p.cmp()
+
+		if err != nil {
+			return nil, err
+		}
+		x = &queryOp{op: "&&", left: x, right: y}
+	}
+}
+
+func (p *queryParser) cmp() (queryExpr, error) {
+	x, err := p.operand()
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.got(op) {
+			y, err := p.operand()
+
+			if err != nil {
+				return nil, err
+			}
+			return &queryOp{op: op, left: x, right: y}, nil
+		}
+	}
+	return x, nil
+}
+
+func (p *queryParser) operand() (queryExpr, error) {
+	p.skipSpace()
+
+	switch b := p.peek(); {
+	case b == '@':
+		p.pos++
+
+		steps, err := p.steps(true)
+
+		if err != nil {
+			return nil, err
+		}
+		return queryPath(steps), nil
+	case b == '!':
+		p.pos++
+
+		x, err := p.operand()
+
+		if err != nil {
+			return nil, err
+		}
+		return &queryNot{x: x}, nil
+	case b == '(':
+		p.pos++
+
+		x, err := p.or()
+
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpace()
+
+		if !p.got(")") {
+			return nil, p.err("expected )")
+		}
+		return x, nil
+	case b == '\'' || b == '"':
+		s, err := p.str()
+
+		if err != nil {
+			return nil, err
+		}
+		return &queryLit{val: value.String{Value: s}}, nil
+	case b == '-' || b >= '0' && b <= '9':
+		start := p.pos
+		p.pos++
+
+		for !p.eof() && (p.s[p.pos] >= '0' && p.s[p.pos] <= '9' || p.s[p.pos] == '.') {
+			p.pos++
+		}
+
+		lit := p.s[start:p.pos]
+
+		if i, err := strconv.ParseInt(lit, 10, 64); err == nil {
+			return &queryLit{val: value.Int{Value: i}}, nil
+		}
+
+		f, err := strconv.ParseFloat(lit, 64)
+
+		if err != nil {
+			return nil, p.err("invalid number " + lit)
+		}
+		return &queryLit{val: value.Float{Value: f}}, nil
+	case p.got("true"):
+		return &queryLit{val: value.Bool{Value: true}}, nil
+	case p.got("false"):
+		return &queryLit{val: value.Bool{}}, nil
+	case p.got("null"):
+		return &queryLit{val: value.Zero{}}, nil
+	}
+	return nil, p.err("expected operand")
+}
+
+// parseQuery parses the given query. The leading $ for the root of the query
+// is optional.
+func parseQuery(s string) (*parsedQuery, error) {
+	p := &queryParser{s: strings.TrimSpace(s)}
+
+	if !p.got("$") {
+		// Allow the first name to be given without a leading dot.
+		if !p.eof() && isQueryNameByte(p.peek()) {
+			p.s = "." + p.s
+		}
+	}
+
+	steps, err := p.steps(false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	q := &parsedQuery{
+		steps:    steps,
+		definite: true,
+	}
+
+	for _, step := range steps {
+		if step.descend || step.kind != childStep && step.kind != indexStep {
+			q.definite = false
+			break
+		}
+	}
+	return q, nil
+}
+
+// children returns the immediate children of the given value.
+func children(v value.Value) []value.Value {
+	switch v := v.(type) {
+	case *value.Array:
+		return v.Items
+	case *value.Object:
+		vals := make([]value.Value, 0, len(v.Order))
+
+		for _, k := range v.Order {
+			vals = append(vals, v.Pairs[k])
+		}
+		return vals
+	}
+	return nil
+}
+
+// descendants returns the given value, followed by all of its descendants.
+func descendants(v value.Value) []value.Value {
+	vals := []value.Value{v}
+
+	for _, child := range children(v) {
+		vals = append(vals, descendants(child)...)
+	}
+	return vals
+}
+
+func (s *queryStep) apply(v value.Value) []value.Value {
+	switch s.kind {
+	case childStep:
+		if obj, ok := v.(*value.Object); ok {
+			if val, ok := obj.Pairs[s.name]; ok {
+				return []value.Value{val}
+			}
+		}
+	case wildcardStep:
+		return children(v)
+	case indexStep:
+		if arr, ok := v.(*value.Array); ok {
+			i := s.index
+
+			if i < 0 {
+				i += len(arr.Items)
+			}
+
+			if i >= 0 && i < len(arr.Items) {
+				return []value.Value{arr.Items[i]}
+			}
+		}
+	case sliceStep:
+		if arr, ok := v.(*value.Array); ok {
+			l := len(arr.Items)
+			i, j := 0, l
+
+			if s.start != nil {
+				i = *s.start
+			}
+			if s.end != nil {
+				j = *s.end
+			}
+
+			if i < 0 {
+				i += l
+			}
+			if j < 0 {
+				j += l
+			}
+
+			i, j = sliceBounds(int64(i), int64(j), l)
+			return arr.Items[i:j]
+		}
+	case filterStep:
+		vals := make([]value.Value, 0)
+
+		for _, child := range children(v) {
+			if queryTruthy(s.filter.eval(child)) {
+				vals = append(vals, child)
+			}
+		}
+		return vals
+	}
+	return nil
+}
+
+func (q *parsedQuery) eval(v value.Value) []value.Value {
+	vals := []value.Value{v}
+
+	for _, step := range q.steps {
+		next := make([]value.Value, 0, len(vals))
+
+		for _, val := range vals {
+			if step.descend {
+				for _, d := range descendants(val) {
+					next = append(next, step.apply(d)...)
+				}
+				continue
+			}
+			next = append(next, step.apply(val)...)
+		}
+		vals = next
+	}
+	return vals
+}
+
+// queryTruthy returns whether the given value is truthy within a filter. Bools
+// are truthy if they are true, any other value is truthy if it exists.
+func queryTruthy(v value.Value) bool {
+	switch v := v.(type) {
+	case value.Bool:
+		return v.Value
+	case value.Zero:
+		return false
+	}
+	return v != nil
+}
+
+func (path queryPath) eval(at value.Value) value.Value {
+	q := &parsedQuery{steps: path}
+	vals := q.eval(at)
+
+	if len(vals) == 0 {
+		return value.Zero{}
+	}
+	return vals[0]
+}
+
+func (l *queryLit) eval(_ value.Value) value.Value {
+	return l.val
+}
+
+func (n *queryNot) eval(at value.Value) value.Value {
+	return value.Bool{Value: !queryTruthy(n.x.eval(at))}
+}
+
+var queryOps = map[string]syntax.Op{
+	"==": syntax.EqOp,
+	"!=": syntax.NeqOp,
+	"<":  syntax.LtOp,
+	"<=": syntax.LeqOp,
+	">":  syntax.GtOp,
+	">=": syntax.GeqOp,
+}
+
+func (o *queryOp) eval(at value.Value) value.Value {
+	switch o.op {
+	case "&&":
+		return value.Bool{Value: queryTruthy(o.left.eval(at)) && queryTruthy(o.right.eval(at))}
+	case "||":
+		return value.Bool{Value: queryTruthy(o.left.eval(at)) || queryTruthy(o.right.eval(at))}
+	}
+
+	a, b := o.left.eval(at), o.right.eval(at)
+
+	// Compare ints and floats as floats.
+	a, b = promoteNumbers(a, b)
+
+	val, err := value.Compare(a, queryOps[o.op], b)
+
+	if err != nil {
+		// Values of different types never match.
+		return value.Bool{Value: o.op == "!="}
+	}
+	return val
+}
+
+// query evaluates the given query against the given value. If the query can
+// only match a single value, then that value is returned, or zero if there is
+// no match. Otherwise an array of all the matches is returned.
+func query(cmd string, args []value.Value) (value.Value, error) {
+	s, err := value.ToString(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	q, err := parseQuery(s.Value)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	vals := q.eval(args[1])
+
+	if q.definite {
+		if len(vals) == 0 {
+			return value.Zero{}, nil
+		}
+		return vals[0], nil
+	}
+	return value.CopyArray(vals), nil
+}
@@ -0,0 +1,209 @@
+package eval
+
+import (
+	"regexp"
+
+	"github.com/andrewpillar/req/value"
+)
+
+// RegexCmd implements the regex family of commands for matching regular
+// expressions against strings and streams. The pattern is always the first
+// argument, and the string or stream is always the last argument.
+var (
+	RegexCmd = &Command{
+		Name: "regex",
+		Argc: -1,
+		Func: family(regextab),
+	}
+
+	regextab = map[string]*Command{
+		"find": {
+			Argc: 2,
+			Func: regexFind,
+		},
+		"findall": {
+			Argc: 2,
+			Func: regexFindAll,
+		},
+		"match": {
+			Argc: 2,
+			Func: regexMatch,
+		},
+		"replace": {
+			Argc: 3,
+			Func: regexReplace,
+		},
+	}
+)
+
+// getRegexArgs returns the compiled pattern from the first argument, and the
+// contents of the string or stream in the last argument.
+func getRegexArgs(args []value.Value) (*regexp.Regexp, []byte, error) {
+	pattern, err := value.ToString(args[0])
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	re, err := regexp.Compile(pattern.Value)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := readBytes(args[len(args)-1])
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return re, b, nil
+}
+
+// submatch returns the value for the given submatch. If the pattern has any
+// named groups, then an object of the named groups is returned, otherwise an
+// array of the entire match followed by each group is returned.
+func submatch(re *regexp.Regexp, match [][]byte) (value.Value, error) {
+	names := re.SubexpNames()
+
+	named := false
+
+	for _, name := range names {
+		if name != "" {
+			named = true
+			break
+		}
+	}
+
+	if named {
+		obj := &value.Object{
+			Order: make([]string, 0, len(names)),
+			Pairs: make(map[string]value.Value),
+		}
+
+		for i, name := range names {
+			if name == "" {
+				continue
+			}
+
+			obj.Order = append(obj.Order, name)
+			obj.Pairs[name] = value.String{Value: string(match[i])}
+		}
+		return obj, nil
+	}
+
+	items := make([]value.Value, 0, len(match))
+
+	for _, b := range match {
+		items = append(items, value.String{Value: string(b)})
+	}
+	return value.NewArray(items)
+}
+
+func regexFind(cmd string, args []value.Value) (value.Value, error) {
+	re, b, err := getRegexArgs(args)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	match := re.FindSubmatch(b)
+
+	if match == nil {
+		return value.Zero{}, nil
+	}
+
+	val, err := submatch(re, match)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return val, nil
+}
+
+func regexFindAll(cmd string, args []value.Value) (value.Value, error) {
+	re, b, err := getRegexArgs(args)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	matches := re.FindAllSubmatch(b, -1)
+	items := make([]value.Value, 0, len(matches))
+
+	for _, match := range matches {
+		// Only return the entire match if there are no groups in the
+		// pattern.
+		if re.NumSubexp() == 0 {
+			items = append(items, value.String{Value: string(match[0])})
+			continue
+		}
+
+		val, err := submatch(re, match)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+		items = append(items, val)
+	}
+
+	arr, err := value.NewArray(items)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return arr, nil
+}
+
+func regexMatch(cmd string, args []value.Value) (value.Value, error) {
+	re, b, err := getRegexArgs(args)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	return value.Bool{
+		Value: re.Match(b),
+	}, nil
+}
+
+func regexReplace(cmd string, args []value.Value) (value.Value, error) {
+	re, b, err := getRegexArgs(args)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	repl, err := value.ToString(args[1])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	return value.String{
+		Value: string(re.ReplaceAll(b, []byte(repl.Value))),
+	}, nil
+}
@@ -0,0 +1,583 @@
+package eval
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// sfToken is a Structured Field token, as described in RFC 8941 section
+// 3.3.4. This is distinct from a string, since the two serialize differently.
+type sfToken string
+
+// sfDecimal is a Structured Field decimal, as described in RFC 8941 section
+// 3.3.2.
+type sfDecimal float64
+
+// sfParam is a single parameter of a Structured Field item or inner list.
+type sfParam struct {
+	key string
+	val interface{}
+}
+
+// sfItem is a Structured Field item, or inner list, as described in RFC 8941
+// section 3. The value is either a bare item, being an int64, sfDecimal,
+// string, sfToken, []byte, or bool, or an []sfItem for an inner list.
+type sfItem struct {
+	val    interface{}
+	params []sfParam
+}
+
+// param returns the value of the given parameter of the item, if any.
+func (it sfItem) param(key string) (interface{}, bool) {
+	for _, p := range it.params {
+		if p.key == key {
+			return p.val, true
+		}
+	}
+	return nil, false
+}
+
+// sfMember is a single member of a Structured Field dictionary.
+type sfMember struct {
+	key  string
+	item sfItem
+}
+
+type sfParser struct {
+	s   string
+	pos int
+}
+
+func (p *sfParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *sfParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *sfParser) skipSP() {
+	for !p.eof() && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *sfParser) skipOWS() {
+	for !p.eof() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *sfParser) errorf(what string) error {
+	return errors.New("malformed structured field: " + what + " at offset " + strconv.Itoa(p.pos))
+}
+
+// members calls fn for each member of a list or dictionary, handling the
+// separating commas, as described in RFC 8941 sections 4.2.1 and 4.2.2.
+func (p *sfParser) members(fn func() error) error {
+	p.skipSP()
+
+	for !p.eof() {
+		if err := fn(); err != nil {
+			return err
+		}
+
+		p.skipOWS()
+
+		if p.eof() {
+			return nil
+		}
+
+		if p.peek() != ',' {
+			return p.errorf("expected comma")
+		}
+
+		p.pos++
+		p.skipOWS()
+
+		if p.eof() {
+			return p.errorf("trailing comma")
+		}
+	}
+	return nil
+}
+
+func (p *sfParser) parseList() ([]sfItem, error) {
+	items := make([]sfItem, 0)
+
+	err := p.members(func() error {
+		it, err := p.parseItemOrInnerList()
+
+		if err != nil {
+			return err
+		}
+
+		items = append(items, it)
+		return nil
+	})
+	return items, err
+}
+
+// parseDict parses a dictionary. If a key appears more than once, then the
+// last value for it is kept, in the position of the first.
+func (p *sfParser) parseDict() ([]sfMember, error) {
+	members := make([]sfMember, 0)
+
+	err := p.members(func() error {
+		key, err := p.parseKey()
+
+		if err != nil {
+			return err
+		}
+
+		var it sfItem
+
+		if p.peek() == '=' {
+			p.pos++
+
+			if it, err = p.parseItemOrInnerList(); err != nil {
+				return err
+			}
+		} else {
+			it.val = true
+
+			if it.params, err = p.parseParams(); err != nil {
+				return err
+			}
+		}
+
+		for i := range members {
+			if members[i].key == key {
+				members[i].item = it
+				return nil
+			}
+		}
+
+		members = append(members, sfMember{key: key, item: it})
+		return nil
+	})
+	return members, err
+}
+
+func (p *sfParser) parseItemOrInnerList() (sfItem, error) {
+	if p.peek() == '(' {
+		return p.parseInnerList()
+	}
+	return p.parseItem()
+}
+
+func (p *sfParser) parseInnerList() (sfItem, error) {
+	p.pos++
+
+	items := make([]sfItem, 0)
+
+	for !p.eof() {
+		p.skipSP()
+
+		if p.peek() == ')' {
+			p.pos++
+
+			params, err := p.parseParams()
+
+			if err != nil {
+				return sfItem{}, err
+			}
+			return sfItem{val: items, params: params}, nil
+		}
+
+		it, err := p.parseItem()
+
+		if err != nil {
+			return sfItem{}, err
+		}
+
+		items = append(items, it)
+
+		if c := p.peek(); c != ' ' && c != ')' {
+			return sfItem{}, p.errorf("expected space or closing parenthesis")
+		}
+	}
+	return sfItem{}, p.errorf("unterminated inner list")
+}
+
+func (p *sfParser) parseItem() (sfItem, error) {
+	val, err := p.parseBareItem()
+
+	if err != nil {
+		return sfItem{}, err
+	}
+
+	params, err := p.parseParams()
+
+	if err != nil {
+		return sfItem{}, err
+	}
+	return sfItem{val: val, params: params}, nil
+}
+
+func (p *sfParser) parseParams() ([]sfParam, error) {
+	params := make([]sfParam, 0)
+
+	for p.peek() == ';' {
+		p.pos++
+		p.skipSP()
+
+		key, err := p.parseKey()
+
+		if err != nil {
+			return nil, err
+		}
+
+		var val interface{} = true
+
+		if p.peek() == '=' {
+			p.pos++
+
+			if val, err = p.parseBareItem(); err != nil {
+				return nil, err
+			}
+		}
+
+		replaced := false
+
+		for i := range params {
+			if params[i].key == key {
+				params[i].val = val
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			params = append(params, sfParam{key: key, val: val})
+		}
+	}
+	return params, nil
+}
+
+func isLcalpha(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isAlpha(c byte) bool {
+	return isLcalpha(c) || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// isTchar reports whether the given byte is a token character, as described in
+// RFC 9110 section 5.6.2.
+func isTchar(c byte) bool {
+	return isAlpha(c) || isDigit(c) || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+func (p *sfParser) parseKey() (string, error) {
+	start := p.pos
+
+	if c := p.peek(); !isLcalpha(c) && c != '*' {
+		return "", p.errorf("expected key")
+	}
+
+	for !p.eof() {
+		c := p.s[p.pos]
+
+		if !isLcalpha(c) && !isDigit(c) && strings.IndexByte("_-.*", c) < 0 {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos], nil
+}
+
+func (p *sfParser) parseBareItem() (interface{}, error) {
+	c := p.peek()
+
+	switch {
+	case c == '-' || isDigit(c):
+		return p.parseNumber()
+	case c == '"':
+		return p.parseString()
+	case c == '*' || isAlpha(c):
+		return p.parseToken()
+	case c == ':':
+		return p.parseByteSequence()
+	case c == '?':
+		return p.parseBoolean()
+	}
+	return nil, p.errorf("unexpected character")
+}
+
+func (p *sfParser) parseNumber() (interface{}, error) {
+	start := p.pos
+
+	if p.peek() == '-' {
+		p.pos++
+	}
+
+	if !isDigit(p.peek()) {
+		return nil, p.errorf("expected digit")
+	}
+
+	dot := -1
+
+	for !p.eof() {
+		c := p.s[p.pos]
+
+		if c == '.' && dot < 0 {
+			dot = p.pos
+		} else if !isDigit(c) {
+			break
+		}
+		p.pos++
+	}
+
+	num := p.s[start:p.pos]
+
+	if dot < 0 {
+		if len(strings.TrimPrefix(num, "-")) > 15 {
+			return nil, p.errorf("integer too long")
+		}
+		return strconv.ParseInt(num, 10, 64)
+	}
+
+	if frac := p.pos - dot - 1; frac < 1 || frac > 3 || dot-start > 12+strings.Count(num[:1], "-") {
+		return nil, p.errorf("invalid decimal")
+	}
+
+	f, err := strconv.ParseFloat(num, 64)
+
+	if err != nil {
+		return nil, err
+	}
+	return sfDecimal(f), nil
+}
+
+func (p *sfParser) parseString() (interface{}, error) {
+	p.pos++
+
+	var buf strings.Builder
+
+	for !p.eof() {
+		c := p.s[p.pos]
+		p.pos++
+
+		switch {
+		case c == '\\':
+			if p.eof() {
+				return nil, p.errorf("unterminated string")
+			}
+
+			next := p.s[p.pos]
+
+			if next != '"' && next != '\\' {
+				return nil, p.errorf("invalid escape")
+			}
+
+			buf.WriteByte(next)
+			p.pos++
+		case c == '"':
+			return buf.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return nil, p.errorf("invalid string character")
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+func (p *sfParser) parseToken() (interface{}, error) {
+	start := p.pos
+
+	for !p.eof() {
+		c := p.s[p.pos]
+
+		if !isTchar(c) && c != ':' && c != '/' {
+			break
+		}
+		p.pos++
+	}
+	return sfToken(p.s[start:p.pos]), nil
+}
+
+func (p *sfParser) parseByteSequence() (interface{}, error) {
+	p.pos++
+
+	end := strings.IndexByte(p.s[p.pos:], ':')
+
+	if end < 0 {
+		return nil, p.errorf("unterminated byte sequence")
+	}
+
+	b, err := base64.StdEncoding.DecodeString(p.s[p.pos : p.pos+end])
+
+	if err != nil {
+		return nil, p.errorf("invalid byte sequence")
+	}
+
+	p.pos += end + 1
+	return b, nil
+}
+
+func (p *sfParser) parseBoolean() (interface{}, error) {
+	p.pos++
+
+	switch p.peek() {
+	case '0':
+		p.pos++
+		return false, nil
+	case '1':
+		p.pos++
+		return true, nil
+	}
+	return nil, p.errorf("invalid boolean")
+}
+
+// sfParse parses the whole of s with the given function, and checks that
+// nothing trails what was parsed.
+func sfParse(s string, fn func(p *sfParser) error) error {
+	p := &sfParser{s: s}
+
+	if err := fn(p); err != nil {
+		return err
+	}
+
+	p.skipSP()
+
+	if !p.eof() {
+		return p.errorf("unexpected character")
+	}
+	return nil
+}
+
+// parseSFList parses the given Structured Field list.
+func parseSFList(s string) ([]sfItem, error) {
+	var items []sfItem
+
+	err := sfParse(s, func(p *sfParser) error {
+		var err error
+
+		items, err = p.parseList()
+		return err
+	})
+	return items, err
+}
+
+// parseSFDict parses the given Structured Field dictionary.
+func parseSFDict(s string) ([]sfMember, error) {
+	var members []sfMember
+
+	err := sfParse(s, func(p *sfParser) error {
+		var err error
+
+		members, err = p.parseDict()
+		return err
+	})
+	return members, err
+}
+
+// parseSFItem parses the given Structured Field item.
+func parseSFItem(s string) (sfItem, error) {
+	var it sfItem
+
+	err := sfParse(s, func(p *sfParser) error {
+		var err error
+
+		p.skipSP()
+
+		it, err = p.parseItem()
+		return err
+	})
+	return it, err
+}
+
+// serializeBareItem returns the serialization of the given bare item, as
+// described in RFC 8941 section 4.1.3.
+func serializeBareItem(val interface{}) string {
+	switch v := val.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case sfDecimal:
+		s := strconv.FormatFloat(float64(v), 'f', 3, 64)
+		s = strings.TrimRight(s, "0")
+
+		if strings.HasSuffix(s, ".") {
+			s += "0"
+		}
+		return s
+	case string:
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+	case sfToken:
+		return string(v)
+	case []byte:
+		return ":" + base64.StdEncoding.EncodeToString(v) + ":"
+	case bool:
+		if v {
+			return "?1"
+		}
+		return "?0"
+	}
+	return ""
+}
+
+func serializeParams(params []sfParam) string {
+	var buf strings.Builder
+
+	for _, p := range params {
+		buf.WriteString(";" + p.key)
+
+		if b, ok := p.val.(bool); !ok || !b {
+			buf.WriteString("=" + serializeBareItem(p.val))
+		}
+	}
+	return buf.String()
+}
+
+// serializeItem returns the serialization of the given item or inner list.
+func serializeItem(it sfItem) string {
+	items, ok := it.val.([]sfItem)
+
+	if !ok {
+		return serializeBareItem(it.val) + serializeParams(it.params)
+	}
+
+	parts := make([]string, 0, len(items))
+
+	for _, item := range items {
+		parts = append(parts, serializeItem(item))
+	}
+	return "(" + strings.Join(parts, " ") + ")" + serializeParams(it.params)
+}
+
+func serializeSFList(items []sfItem) string {
+	parts := make([]string, 0, len(items))
+
+	for _, it := range items {
+		parts = append(parts, serializeItem(it))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// serializeMember returns the serialization of the given dictionary member.
+// Members with a value of true are serialized as just the key.
+func serializeMember(m sfMember) string {
+	if b, ok := m.item.val.(bool); ok && b {
+		return m.key + serializeParams(m.item.params)
+	}
+	return m.key + "=" + serializeItem(m.item)
+}
+
+func serializeSFDict(members []sfMember) string {
+	parts := make([]string, 0, len(members))
+
+	for _, m := range members {
+		parts = append(parts, serializeMember(m))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,343 @@
+package eval
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andrewpillar/req/value"
+)
+
+// SigV4Cmd implements the sigv4 command for signing a request with AWS
+// Signature Version 4.
+var SigV4Cmd = &Command{
+	Name: "sigv4",
+	Argc: 2,
+	Func: sigv4,
+}
+
+const (
+	sigv4Algorithm       = "AWS4-HMAC-SHA256"
+	sigv4UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// sigv4Unsigned is the set of headers that are not included in the signature.
+// These are headers that may be modified in transit.
+var sigv4Unsigned = map[string]struct{}{
+	"authorization":   {},
+	"user-agent":      {},
+	"x-amzn-trace-id": {},
+	"expect":          {},
+}
+
+type sigv4Signer struct {
+	accessKey       string
+	secretKey       string
+	sessionToken    string
+	region          string
+	service         string
+	unsignedPayload bool
+
+	now func() time.Time
+}
+
+func newSigV4Signer(obj *value.Object) (*sigv4Signer, error) {
+	var (
+		s   sigv4Signer
+		err error
+	)
+
+	fields := []struct {
+		key string
+		p   *string
+	}{
+		{"AccessKey", &s.accessKey},
+		{"SecretKey", &s.secretKey},
+		{"SessionToken", &s.sessionToken},
+		{"Region", &s.region},
+		{"Service", &s.service},
+	}
+
+	for _, fld := range fields {
+		*fld.p, err = getString(obj, fld.key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if *fld.p == "" && fld.key != "SessionToken" {
+			return nil, errors.New("key error " + fld.key + ": not set")
+		}
+	}
+
+	s.unsignedPayload, err = getBool(obj, "UnsignedPayload")
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.now = time.Now
+	return &s, nil
+}
+
+// sigv4Escape escapes the given string as described in the SigV4
+// documentation, whereby all characters except for the unreserved characters
+// are percent encoded. If path is true, then / is left as is.
+func sigv4Escape(s string, path bool) string {
+	var buf strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' || (path && c == '/') {
+			buf.WriteByte(c)
+			continue
+		}
+
+		buf.WriteByte('%')
+		buf.WriteString(strings.ToUpper(hex.EncodeToString([]byte{c})))
+	}
+	return buf.String()
+}
+
+// canonicalURI returns the canonical URI of the request. This is built from
+// the decoded path, since Go leaves some reserved characters unescaped.
+func (s *sigv4Signer) canonicalURI(r *http.Request) string {
+	path := r.URL.Path
+
+	if path == "" {
+		return "/"
+	}
+
+	path = sigv4Escape(path, true)
+
+	// S3 is the only service where the path is not encoded twice.
+	if s.service == "s3" {
+		return path
+	}
+	return sigv4Escape(path, true)
+}
+
+// canonicalQuery returns the canonical query string of the request. The
+// parameters are sorted by their encoded key, then by their encoded value, so
+// a key sorts before any longer key it is a prefix of.
+func canonicalQuery(r *http.Request) string {
+	query := r.URL.Query()
+
+	params := make([][2]string, 0, len(query))
+
+	for key, vals := range query {
+		for _, val := range vals {
+			params = append(params, [2]string{sigv4Escape(key, false), sigv4Escape(val, false)})
+		}
+	}
+
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+		return params[i][1] < params[j][1]
+	})
+
+	parts := make([]string, 0, len(params))
+
+	for _, p := range params {
+		parts = append(parts, p[0]+"="+p[1])
+	}
+	return strings.Join(parts, "&")
+}
+
+// canonicalHeaders returns the canonical headers of the request, and the list
+// of signed headers.
+func canonicalHeaders(r *http.Request) (string, string) {
+	host := r.Host
+
+	if host == "" {
+		host = r.URL.Host
+	}
+
+	hdrs := map[string]string{
+		"host": host,
+	}
+
+	for key, vals := range r.Header {
+		key = strings.ToLower(key)
+
+		if _, ok := sigv4Unsigned[key]; ok {
+			continue
+		}
+
+		trimmed := make([]string, 0, len(vals))
+
+		for _, val := range vals {
+			trimmed = append(trimmed, strings.Join(strings.Fields(val), " "))
+		}
+		hdrs[key] = strings.Join(trimmed, ",")
+	}
+
+	keys := make([]string, 0, len(hdrs))
+
+	for key := range hdrs {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var buf strings.Builder
+
+	for _, key := range keys {
+		buf.WriteString(key + ":" + hdrs[key] + "\n")
+	}
+	return buf.String(), strings.Join(keys, ";")
+}
+
+// payloadHash returns the hex encoded SHA256 hash of the request body. The
+// body is hashed as it is read, and is then reset so it can be sent.
+func (s *sigv4Signer) payloadHash(r *http.Request) (string, error) {
+	if s.unsignedPayload {
+		return sigv4UnsignedPayload, nil
+	}
+
+	h := sha256.New()
+
+	if r.GetBody != nil {
+		rc, err := r.GetBody()
+
+		if err != nil {
+			return "", err
+		}
+
+		_, err = io.Copy(h, rc)
+
+		rc.Close()
+
+		if err != nil {
+			return "", err
+		}
+
+		r.Body, err = r.GetBody()
+
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	io.WriteString(h, s)
+	return h.Sum(nil)
+}
+
+// sign signs the given request, setting the Authorization header along with
+// the X-Amz-* headers used as part of the signature.
+func (s *sigv4Signer) sign(r *http.Request) error {
+	t := s.now().UTC()
+
+	amzdate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	r.Header.Del("Authorization")
+	r.Header.Set("X-Amz-Date", amzdate)
+
+	if s.sessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	payload, err := s.payloadHash(r)
+
+	if err != nil {
+		return err
+	}
+
+	if s.service == "s3" || s.unsignedPayload {
+		r.Header.Set("X-Amz-Content-Sha256", payload)
+	}
+
+	hdrs, signed := canonicalHeaders(r)
+
+	canonical := strings.Join([]string{
+		r.Method,
+		s.canonicalURI(r),
+		canonicalQuery(r),
+		hdrs,
+		signed,
+		payload,
+	}, "\n")
+
+	hash := sha256.Sum256([]byte(canonical))
+
+	scope := date + "/" + s.region + "/" + s.service + "/aws4_request"
+
+	tosign := sigv4Algorithm + "\n" + amzdate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	key = hmacSHA256(key, "aws4_request")
+
+	sig := hex.EncodeToString(hmacSHA256(key, tosign))
+
+	r.Header.Set("Authorization", sigv4Algorithm+" Credential="+s.accessKey+"/"+scope+", SignedHeaders="+signed+", Signature="+sig)
+	return nil
+}
+
+// transport returns middleware that signs the request just before it is
+// sent, so any headers set on the request beforehand are signed.
+func (s *sigv4Signer) transport(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		r2, err := bufferBody(r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if r2 == r {
+			r2 = r.Clone(r.Context())
+		}
+
+		if err := s.sign(r2); err != nil {
+			return nil, errors.New("sigv4: " + err.Error())
+		}
+		return next.RoundTrip(r2)
+	})
+}
+
+func sigv4(cmd string, args []value.Value) (value.Value, error) {
+	obj, err := value.ToObject(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	req, err := value.ToRequest(args[1])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	s, err := newSigV4Signer(obj)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	req.Use(s.transport)
+	return req, nil
+}
@@ -0,0 +1,475 @@
+package eval
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/andrewpillar/req/value"
+)
+
+// StrCmd implements the str family of commands for working with strings. The
+// string being operated on is always the last argument, so these commands can
+// be chained.
+var (
+	StrCmd = &Command{
+		Name: "str",
+		Argc: -1,
+		Func: family(strtab),
+	}
+
+	strtab = map[string]*Command{
+		"contains": {
+			Argc: 2,
+			Func: strPredicate(strings.Contains),
+		},
+		"has-prefix": {
+			Argc: 2,
+			Func: strPredicate(strings.HasPrefix),
+		},
+		"has-suffix": {
+			Argc: 2,
+			Func: strPredicate(strings.HasSuffix),
+		},
+		"index": {
+			Argc: 2,
+			Func: strIndex,
+		},
+		"join": {
+			Argc: 2,
+			Func: strJoin,
+		},
+		"lower": {
+			Argc: 1,
+			Func: strLower,
+		},
+		"pad": {
+			Argc: -1,
+			Func: strPad,
+		},
+		"repeat": {
+			Argc: 2,
+			Func: strRepeat,
+		},
+		"replace": {
+			Argc: 3,
+			Func: strReplace,
+		},
+		"split": {
+			Argc: 2,
+			Func: strSplit,
+		},
+		"trim": {
+			Argc: -1,
+			Func: strTrim,
+		},
+		"upper": {
+			Argc: 1,
+			Func: strUpper,
+		},
+	}
+)
+
+// maxStringLen is the maximum length of a string that can be built by the
+// repeat and pad commands.
+const maxStringLen = 64 << 20
+
+// mapString calls the given function on the given string. If an array is
+// given, then the function is called on each string in the array, and a new
+// array of the results is returned.
+func mapString(val value.Value, fn func(string) string) (value.Value, error) {
+	return tryMapString(val, func(s string) (string, error) {
+		return fn(s), nil
+	})
+}
+
+// tryMapString is like mapString, only the given function can return an
+// error.
+func tryMapString(val value.Value, fn func(string) (string, error)) (value.Value, error) {
+	switch v := val.(type) {
+	case value.String:
+		s, err := fn(v.Value)
+
+		if err != nil {
+			return nil, err
+		}
+		return value.String{Value: s}, nil
+	case *value.Array:
+		items := make([]value.Value, 0, len(v.Items))
+
+		for _, it := range v.Items {
+			str, err := value.ToString(it)
+
+			if err != nil {
+				return nil, err
+			}
+
+			s, err := fn(str.Value)
+
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value.String{Value: s})
+		}
+		return value.NewArray(items)
+	default:
+		return nil, errors.New("cannot use type " + value.Type(val) + " as string or array")
+	}
+}
+
+// repeatString returns the string repeated n times. An error is returned if
+// the result would be longer than maxStringLen.
+func repeatString(s string, n int64) (string, error) {
+	if s != "" && n > maxStringLen/int64(len(s)) {
+		return "", errors.New("string too long")
+	}
+	return strings.Repeat(s, int(n)), nil
+}
+
+func strPredicate(fn func(string, string) bool) CommandFunc {
+	return func(cmd string, args []value.Value) (value.Value, error) {
+		substr, err := value.ToString(args[0])
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		str, err := value.ToString(args[1])
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		return value.Bool{
+			Value: fn(str.Value, substr.Value),
+		}, nil
+	}
+}
+
+func strIndex(cmd string, args []value.Value) (value.Value, error) {
+	substr, err := value.ToString(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	str, err := value.ToString(args[1])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	return value.Int{
+		Value: int64(strings.Index(str.Value, substr.Value)),
+	}, nil
+}
+
+func strJoin(cmd string, args []value.Value) (value.Value, error) {
+	sep, err := value.ToString(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	arr, ok := args[1].(*value.Array)
+
+	if !ok {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("cannot use type " + value.Type(args[1]) + " as array"),
+		}
+	}
+
+	parts := make([]string, 0, len(arr.Items))
+
+	for _, it := range arr.Items {
+		parts = append(parts, it.Sprint())
+	}
+
+	return value.String{
+		Value: strings.Join(parts, sep.Value),
+	}, nil
+}
+
+func strLower(cmd string, args []value.Value) (value.Value, error) {
+	val, err := mapString(args[0], strings.ToLower)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return val, nil
+}
+
+func strUpper(cmd string, args []value.Value) (value.Value, error) {
+	val, err := mapString(args[0], strings.ToUpper)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return val, nil
+}
+
+// strPad pads the string to the given width. By default the string is padded
+// on the left with spaces, unless the right direction is given.
+func strPad(cmd string, args []value.Value) (value.Value, error) {
+	if l := len(args); l < 2 || l > 4 {
+		err := errNotEnoughArgs
+
+		if l > 4 {
+			err = errTooManyArgs
+		}
+
+		return nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	right := false
+
+	if name, ok := args[0].(value.Name); ok {
+		switch name.Value {
+		case "left":
+		case "right":
+			right = true
+		default:
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: errors.New("unexpected pad direction " + name.Value),
+			}
+		}
+		args = args[1:]
+	}
+
+	if len(args) < 2 {
+		return nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: errNotEnoughArgs,
+		}
+	}
+
+	width, err := value.ToInt(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	pad := " "
+
+	if len(args) > 2 {
+		str, err := value.ToString(args[1])
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		if str.Value == "" {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: errors.New("cannot pad with empty string"),
+			}
+		}
+		pad = str.Value
+	}
+
+	val, err := tryMapString(args[len(args)-1], func(s string) (string, error) {
+		n := width.Value - int64(len([]rune(s)))
+
+		if n <= 0 {
+			return s, nil
+		}
+
+		fill, err := repeatString(pad, n)
+
+		if err != nil {
+			return "", err
+		}
+
+		fill = string([]rune(fill)[:n])
+
+		if right {
+			return s + fill, nil
+		}
+		return fill + s, nil
+	})
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return val, nil
+}
+
+func strRepeat(cmd string, args []value.Value) (value.Value, error) {
+	n, err := value.ToInt(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	if n.Value < 0 {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("negative repeat count"),
+		}
+	}
+
+	val, err := tryMapString(args[1], func(s string) (string, error) {
+		return repeatString(s, n.Value)
+	})
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return val, nil
+}
+
+func strReplace(cmd string, args []value.Value) (value.Value, error) {
+	old, err := value.ToString(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	repl, err := value.ToString(args[1])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	val, err := mapString(args[2], func(s string) string {
+		return strings.Replace(s, old.Value, repl.Value, -1)
+	})
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return val, nil
+}
+
+func strSplit(cmd string, args []value.Value) (value.Value, error) {
+	sep, err := value.ToString(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	str, err := value.ToString(args[1])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	parts := strings.Split(str.Value, sep.Value)
+	items := make([]value.Value, 0, len(parts))
+
+	for _, part := range parts {
+		items = append(items, value.String{Value: part})
+	}
+
+	arr, err := value.NewArray(items)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return arr, nil
+}
+
+// strTrim trims the given cutset from either side of the string. If no cutset
+// is given, then whitespace is trimmed.
+func strTrim(cmd string, args []value.Value) (value.Value, error) {
+	if l := len(args); l < 1 || l > 2 {
+		err := errNotEnoughArgs
+
+		if l > 2 {
+			err = errTooManyArgs
+		}
+
+		return nil, &CommandError{
+			Op:  "call",
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	fn := strings.TrimSpace
+
+	if len(args) > 1 {
+		cutset, err := value.ToString(args[0])
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+
+		fn = func(s string) string {
+			return strings.Trim(s, cutset.Value)
+		}
+	}
+
+	val, err := mapString(args[len(args)-1], fn)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+	return val, nil
+}
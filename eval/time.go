@@ -0,0 +1,191 @@
+package eval
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewpillar/req/value"
+)
+
+// NowCmd implements the now command for getting the current time.
+var NowCmd = &Command{
+	Name: "now",
+	Argc: 0,
+	Func: now,
+}
+
+// ParseCmd implements the parse family of commands for parsing strings into
+// other values. Each parse command has a respective format command for
+// formatting values into strings.
+var (
+	ParseCmd = &Command{
+		Name: "parse",
+		Argc: -1,
+		Func: family(parsetab),
+	}
+
+	parsetab = map[string]*Command{
+		"time": {
+			Argc: 2,
+			Func: parseTime,
+		},
+	}
+)
+
+// FormatCmd implements the format family of commands for formatting values
+// into strings. Each format command has a respective parse command for parsing
+// strings back into their original value.
+var (
+	FormatCmd = &Command{
+		Name: "format",
+		Argc: -1,
+		Func: family(formattab),
+	}
+
+	formattab = map[string]*Command{
+		"time": {
+			Argc: 2,
+			Func: formatTime,
+		},
+	}
+)
+
+// timeLayouts is the table of the named layouts that can be used for parsing
+// and formatting times. The Unix and UnixMilli layouts are handled separately.
+var timeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"DateOnly":    "2006-01-02",
+	"DateTime":    "2006-01-02 15:04:05",
+	"HTTP":        http.TimeFormat,
+	"Kitchen":     time.Kitchen,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"TimeOnly":    "15:04:05",
+	"UnixDate":    time.UnixDate,
+}
+
+// getTimeLayout returns the layout for the given value. If the value is a
+// name, then the named layout is returned, otherwise the value is expected to
+// be a string containing a layout using the reference time.
+func getTimeLayout(val value.Value) (string, error) {
+	if name, ok := val.(value.Name); ok {
+		if name.Value == "Unix" || name.Value == "UnixMilli" {
+			return name.Value, nil
+		}
+
+		layout, ok := timeLayouts[name.Value]
+
+		if !ok {
+			return "", errors.New("unknown time layout " + name.Value)
+		}
+		return layout, nil
+	}
+
+	str, err := value.ToString(val)
+
+	if err != nil {
+		return "", errors.New("cannot use type " + value.Type(val) + " as time layout")
+	}
+	return str.Value, nil
+}
+
+func now(cmd string, args []value.Value) (value.Value, error) {
+	return value.Time{Value: time.Now()}, nil
+}
+
+func parseTime(cmd string, args []value.Value) (value.Value, error) {
+	layout, err := getTimeLayout(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	var s string
+
+	switch v := args[1].(type) {
+	case value.String:
+		s = strings.TrimSpace(v.Value)
+	case value.Int:
+		s = v.String()
+	default:
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: errors.New("cannot parse " + value.Type(v)),
+		}
+	}
+
+	var t time.Time
+
+	switch layout {
+	case "Unix", "UnixMilli":
+		i, err := strconv.ParseInt(s, 10, 64)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: errors.New("cannot parse " + strconv.Quote(s) + " as " + layout + " time"),
+			}
+		}
+
+		if layout == "Unix" {
+			t = time.Unix(i, 0)
+			break
+		}
+		t = time.Unix(0, i*int64(time.Millisecond))
+	default:
+		t, err = time.Parse(layout, s)
+
+		if err != nil {
+			return nil, &CommandError{
+				Cmd: cmd,
+				Err: err,
+			}
+		}
+	}
+	return value.Time{Value: t}, nil
+}
+
+func formatTime(cmd string, args []value.Value) (value.Value, error) {
+	layout, err := getTimeLayout(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	t, err := value.ToTime(args[1])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	var s string
+
+	switch layout {
+	case "Unix":
+		s = strconv.FormatInt(t.Value.Unix(), 10)
+	case "UnixMilli":
+		s = strconv.FormatInt(t.Value.UnixNano()/int64(time.Millisecond), 10)
+	case http.TimeFormat:
+		s = t.Value.UTC().Format(layout)
+	default:
+		s = t.Value.Format(layout)
+	}
+	return value.String{Value: s}, nil
+}
@@ -0,0 +1,768 @@
+package eval
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/andrewpillar/req/value"
+)
+
+// XPathCmd implements the xpath command for extracting nodes from XML with an
+// XPath expression. This supports a subset of XPath 1.0, namely the child,
+// descendant, parent, and attribute axes, along with predicates.
+var XPathCmd = &Command{
+	Name: "xpath",
+	Argc: 2,
+	Func: xpath,
+}
+
+type xpathAxis uint
+
+const (
+	childAxis xpathAxis = iota + 1
+	attrAxis
+	selfAxis
+	parentAxis
+)
+
+type xpathTest uint
+
+const (
+	nameTest xpathTest = iota + 1
+	textTest
+	nodeTest
+)
+
+// xpathStep is a single step in a location path. If descend is true, then the
+// step is applied to the context nodes and all of their descendants.
+type xpathStep struct {
+	axis    xpathAxis
+	test    xpathTest
+	name    string
+	descend bool
+	preds   []xpathExpr
+}
+
+// xpathCtx is the context an expression is evaluated in. The position is
+// 1-based, as it is in XPath.
+type xpathCtx struct {
+	node *value.XMLNode
+	pos  int
+	size int
+}
+
+// xpathExpr is an expression within an XPath. The result of evaluating an
+// expression is either a []*value.XMLNode, string, float64, or bool.
+type xpathExpr interface {
+	eval(ctx xpathCtx) interface{}
+}
+
+// xpathPath is a location path. If abs is true, then the path starts from the
+// document node, otherwise it starts from the context node.
+type xpathPath struct {
+	abs   bool
+	steps []*xpathStep
+}
+
+// xpathLit is a string or number literal.
+type xpathLit struct {
+	val interface{}
+}
+
+// xpathCall is a call to one of the supported functions.
+type xpathCall struct {
+	name string
+	args []xpathExpr
+}
+
+// xpathOp is a comparison, or logical operation.
+type xpathOp struct {
+	op          string
+	left, right xpathExpr
+}
+
+type xpathParser struct {
+	queryParser
+}
+
+func (p *xpathParser) err(msg string) error {
+	return errors.New("invalid xpath at offset " + strconv.Itoa(p.pos) + ": " + msg)
+}
+
+func isXPathNameByte(b byte) bool {
+	return isQueryNameByte(b) || b == '.' || b == ':'
+}
+
+func (p *xpathParser) name() (string, error) {
+	start := p.pos
+
+	if b := p.peek(); b == '.' || b == '-' || b >= '0' && b <= '9' {
+		return "", p.err("expected name")
+	}
+
+	for !p.eof() && isXPathNameByte(p.s[p.pos]) {
+		p.pos++
+	}
+
+	if start == p.pos {
+		return "", p.err("expected name")
+	}
+	return p.s[start:p.pos], nil
+}
+
+// path parses a location path. A path is absolute if it begins with either
+// / or //.
+func (p *xpathParser) path() (*xpathPath, error) {
+	path := &xpathPath{}
+
+	descend := false
+
+	if p.peek() == '/' {
+		path.abs = true
+
+		if p.got("//") {
+			descend = true
+		} else {
+			p.pos++
+
+			// A lone / selects the document.
+			if p.eof() || strings.IndexByte("])=!<> ", p.peek()) >= 0 {
+				return path, nil
+			}
+		}
+	}
+
+	for {
+		step, err := p.step()
+
+		if err != nil {
+			return nil, err
+		}
+
+		step.descend = descend
+		path.steps = append(path.steps, step)
+
+		if p.got("//") {
+			descend = true
+			continue
+		}
+
+		if p.got("/") {
+			descend = false
+			continue
+		}
+		return path, nil
+	}
+}
+
+func (p *xpathParser) step() (*xpathStep, error) {
+	step := &xpathStep{
+		axis: childAxis,
+		test: nameTest,
+	}
+
+	switch {
+	case p.got(".."):
+		step.axis = parentAxis
+		step.test = nodeTest
+		return step, nil
+	case p.got("."):
+		step.axis = selfAxis
+		step.test = nodeTest
+		return step, nil
+	case p.got("@"):
+		step.axis = attrAxis
+	}
+
+	if p.got("*") {
+		step.name = "*"
+	} else {
+		name, err := p.name()
+
+		if err != nil {
+			return nil, err
+		}
+
+		step.name = name
+
+		if step.axis == childAxis && p.got("()") {
+			switch name {
+			case "text":
+				step.test = textTest
+			case "node":
+				step.test = nodeTest
+			default:
+				return nil, p.err("unexpected node test " + name + "()")
+			}
+		}
+	}
+
+	for p.got("[") {
+		p.skipSpace()
+
+		pred, err := p.or()
+
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpace()
+
+		if !p.got("]") {
+			return nil, p.err("expected ]")
+		}
+		step.preds = append(step.preds, pred)
+	}
+	return step, nil
+}
+
+// keyword reports whether the given keyword is next, and consumes it if so.
+// The keyword must not be followed by another name byte.
+func (p *xpathParser) keyword(kw string) bool {
+	if !strings.HasPrefix(p.s[p.pos:], kw) {
+		return false
+	}
+
+	if end := p.pos + len(kw); end < len(p.s) && isXPathNameByte(p.s[end]) {
+		return false
+	}
+
+	p.pos += len(kw)
+	return true
+}
+
+func (p *xpathParser) or() (xpathExpr, error) {
+	left, err := p.and()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+
+		if !p.keyword("or") {
+			return left, nil
+		}
+
+		p.skipSpace()
+
+		right, err := p.and()
+
+		if err != nil {
+			return nil, err
+		}
+		left = &xpathOp{op: "or", left: left, right: right}
+	}
+}
+
+func (p *xpathParser) and() (xpathExpr, error) {
+	left, err := p.cmp()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+
+		if !p.keyword("and") {
+			return left, nil
+		}
+
+		p.skipSpace()
+
+		right, err := p.cmp()
+
+		if err != nil {
+			return nil, err
+		}
+		left = &xpathOp{op: "and", left: left, right: right}
+	}
+}
+
+func (p *xpathParser) cmp() (xpathExpr, error) {
+	left, err := p.operand()
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+
+	for _, op := range []string{"!=", "<=", ">=", "=", "<", ">"} {
+		if p.got(op) {
+			p.skipSpace()
+
+			right, err := p.operand()
+
+			if err != nil {
+				return nil, err
+			}
+			return &xpathOp{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+// xpathFuncs is the number of arguments each supported function takes.
+var xpathFuncs = map[string]int{
+	"contains":    2,
+	"count":       1,
+	"last":        0,
+	"not":         1,
+	"position":    0,
+	"starts-with": 2,
+}
+
+func (p *xpathParser) operand() (xpathExpr, error) {
+	switch b := p.peek(); {
+	case b == '(':
+		p.pos++
+		p.skipSpace()
+
+		x, err := p.or()
+
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpace()
+
+		if !p.got(")") {
+			return nil, p.err("expected )")
+		}
+		return x, nil
+	case b == '\'' || b == '"':
+		s, err := p.str()
+
+		if err != nil {
+			return nil, err
+		}
+		return &xpathLit{val: s}, nil
+	case b >= '0' && b <= '9':
+		start := p.pos
+
+		for !p.eof() && (p.peek() == '.' || p.peek() >= '0' && p.peek() <= '9') {
+			p.pos++
+		}
+
+		f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+
+		if err != nil {
+			p.pos = start
+			return nil, p.err("expected number")
+		}
+		return &xpathLit{val: f}, nil
+	}
+
+	for name, argc := range xpathFuncs {
+		start := p.pos
+
+		if !p.keyword(name) {
+			continue
+		}
+
+		p.skipSpace()
+
+		if !p.got("(") {
+			p.pos = start
+			continue
+		}
+
+		call := &xpathCall{name: name}
+
+		for i := 0; i < argc; i++ {
+			p.skipSpace()
+
+			if i > 0 && !p.got(",") {
+				return nil, p.err("expected ,")
+			}
+
+			p.skipSpace()
+
+			arg, err := p.or()
+
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+		}
+
+		p.skipSpace()
+
+		if !p.got(")") {
+			return nil, p.err("expected )")
+		}
+		return call, nil
+	}
+	return p.path()
+}
+
+func parseXPath(s string) (xpathExpr, error) {
+	p := &xpathParser{
+		queryParser: queryParser{s: strings.TrimSpace(s)},
+	}
+
+	if p.eof() {
+		return nil, p.err("empty xpath")
+	}
+
+	x, err := p.or()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.eof() {
+		return nil, p.err("unexpected " + string(p.peek()))
+	}
+	return x, nil
+}
+
+// matchName reports whether the given node name matches the name in a step.
+// If the name in the step has no prefix, then only the local part of the node
+// name is compared.
+func matchName(name, test string) bool {
+	if test == "*" || name == test {
+		return true
+	}
+
+	if strings.Contains(test, ":") {
+		return false
+	}
+
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		return name[i+1:] == test
+	}
+	return false
+}
+
+// xpathDescendants returns the given node and all of its descendants in
+// document order.
+func xpathDescendants(n *value.XMLNode) []*value.XMLNode {
+	nodes := []*value.XMLNode{n}
+
+	for _, child := range n.Children {
+		nodes = append(nodes, xpathDescendants(child)...)
+	}
+	return nodes
+}
+
+// candidates returns the nodes selected by the axis and node test of the step
+// from the given context node.
+func (s *xpathStep) candidates(n *value.XMLNode) []*value.XMLNode {
+	nodes := make([]*value.XMLNode, 0)
+
+	switch s.axis {
+	case selfAxis:
+		return append(nodes, n)
+	case parentAxis:
+		if n.Parent != nil {
+			nodes = append(nodes, n.Parent)
+		}
+		return nodes
+	case attrAxis:
+		for _, a := range n.Attrs {
+			if matchName(a.Name, s.name) {
+				nodes = append(nodes, a)
+			}
+		}
+		return nodes
+	}
+
+	for _, child := range n.Children {
+		switch s.test {
+		case nodeTest:
+			nodes = append(nodes, child)
+		case textTest:
+			if child.Kind == value.XMLText {
+				nodes = append(nodes, child)
+			}
+		case nameTest:
+			if child.Kind == value.XMLElement && matchName(child.Name, s.name) {
+				nodes = append(nodes, child)
+			}
+		}
+	}
+	return nodes
+}
+
+func (s *xpathStep) apply(ctx []*value.XMLNode) []*value.XMLNode {
+	if s.descend {
+		all := make([]*value.XMLNode, 0, len(ctx))
+
+		for _, n := range ctx {
+			all = append(all, xpathDescendants(n)...)
+		}
+		ctx = all
+	}
+
+	nodes := make([]*value.XMLNode, 0)
+	seen := make(map[*value.XMLNode]struct{})
+
+	for _, n := range ctx {
+		cands := s.candidates(n)
+
+		for _, pred := range s.preds {
+			filtered := make([]*value.XMLNode, 0, len(cands))
+
+			for i, c := range cands {
+				res := pred.eval(xpathCtx{
+					node: c,
+					pos:  i + 1,
+					size: len(cands),
+				})
+
+				if f, ok := res.(float64); ok {
+					if int(f) == i+1 {
+						filtered = append(filtered, c)
+					}
+					continue
+				}
+
+				if xpathBool(res) {
+					filtered = append(filtered, c)
+				}
+			}
+			cands = filtered
+		}
+
+		for _, c := range cands {
+			if _, ok := seen[c]; !ok {
+				seen[c] = struct{}{}
+				nodes = append(nodes, c)
+			}
+		}
+	}
+	return nodes
+}
+
+func (p *xpathPath) eval(ctx xpathCtx) interface{} {
+	n := ctx.node
+
+	if p.abs {
+		for n.Parent != nil {
+			n = n.Parent
+		}
+	}
+
+	nodes := []*value.XMLNode{n}
+
+	for _, step := range p.steps {
+		nodes = step.apply(nodes)
+	}
+	return nodes
+}
+
+func (l *xpathLit) eval(_ xpathCtx) interface{} {
+	return l.val
+}
+
+func (c *xpathCall) eval(ctx xpathCtx) interface{} {
+	switch c.name {
+	case "last":
+		return float64(ctx.size)
+	case "position":
+		return float64(ctx.pos)
+	case "not":
+		return !xpathBool(c.args[0].eval(ctx))
+	case "count":
+		nodes, _ := c.args[0].eval(ctx).([]*value.XMLNode)
+		return float64(len(nodes))
+	case "contains":
+		return strings.Contains(xpathString(c.args[0].eval(ctx)), xpathString(c.args[1].eval(ctx)))
+	case "starts-with":
+		return strings.HasPrefix(xpathString(c.args[0].eval(ctx)), xpathString(c.args[1].eval(ctx)))
+	}
+	return false
+}
+
+func xpathBool(v interface{}) bool {
+	switch v := v.(type) {
+	case []*value.XMLNode:
+		return len(v) > 0
+	case string:
+		return v != ""
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case bool:
+		return v
+	}
+	return false
+}
+
+func xpathString(v interface{}) string {
+	switch v := v.(type) {
+	case []*value.XMLNode:
+		if len(v) == 0 {
+			return ""
+		}
+		return v[0].Text()
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+func xpathNumber(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(xpathString(v)), 64)
+
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+// xpathCompare compares the two given values with the given operator. If
+// either value is a node set, then the comparison is true if it is true for
+// any of the nodes in the set.
+func xpathCompare(op string, a, b interface{}) bool {
+	if nodes, ok := a.([]*value.XMLNode); ok {
+		if _, ok := b.(bool); ok {
+			return xpathCompare(op, xpathBool(a), b)
+		}
+
+		for _, n := range nodes {
+			if xpathCompare(op, n.Text(), b) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if nodes, ok := b.([]*value.XMLNode); ok {
+		if _, ok := a.(bool); ok {
+			return xpathCompare(op, a, xpathBool(b))
+		}
+
+		for _, n := range nodes {
+			if xpathCompare(op, a, n.Text()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch op {
+	case "=", "!=":
+		var eq bool
+
+		_, aok := a.(bool)
+		_, bok := b.(bool)
+
+		_, afloat := a.(float64)
+		_, bfloat := b.(float64)
+
+		switch {
+		case aok || bok:
+			eq = xpathBool(a) == xpathBool(b)
+		case afloat || bfloat:
+			eq = xpathNumber(a) == xpathNumber(b)
+		default:
+			eq = xpathString(a) == xpathString(b)
+		}
+
+		if op == "=" {
+			return eq
+		}
+		return !eq
+	}
+
+	x, y := xpathNumber(a), xpathNumber(b)
+
+	switch op {
+	case "<":
+		return x < y
+	case "<=":
+		return x <= y
+	case ">":
+		return x > y
+	case ">=":
+		return x >= y
+	}
+	return false
+}
+
+func (o *xpathOp) eval(ctx xpathCtx) interface{} {
+	switch o.op {
+	case "and":
+		return xpathBool(o.left.eval(ctx)) && xpathBool(o.right.eval(ctx))
+	case "or":
+		return xpathBool(o.left.eval(ctx)) || xpathBool(o.right.eval(ctx))
+	}
+	return xpathCompare(o.op, o.left.eval(ctx), o.right.eval(ctx))
+}
+
+// xpathValue returns the value for the result of an XPath expression. A node
+// set is returned as an array of the values of each node.
+func xpathValue(v interface{}) value.Value {
+	switch v := v.(type) {
+	case []*value.XMLNode:
+		vals := make([]value.Value, 0, len(v))
+
+		for _, n := range v {
+			vals = append(vals, n.Value())
+		}
+		return value.CopyArray(vals)
+	case string:
+		return value.String{Value: v}
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < math.MaxInt64 {
+			return value.Int{Value: int64(v)}
+		}
+		return value.Float{Value: v}
+	case bool:
+		return value.Bool{Value: v}
+	}
+	return value.Zero{}
+}
+
+// xpath evaluates the given XPath against the given XML. If the XPath selects
+// nodes, then an array of the values of the matched nodes is returned.
+func xpath(cmd string, args []value.Value) (value.Value, error) {
+	s, err := value.ToString(args[0])
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	x, err := parseXPath(s.Value)
+
+	if err != nil {
+		return nil, &CommandError{
+			Cmd: cmd,
+			Err: err,
+		}
+	}
+
+	return decodeFrom(cmd, args[1], func(r io.Reader) (value.Value, error) {
+		doc, err := value.ParseXML(r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return xpathValue(x.eval(xpathCtx{node: doc, pos: 1, size: 1})), nil
+	})
+}
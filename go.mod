@@ -1,9 +1,21 @@
 module github.com/andrewpillar/req
 
-go 1.17
+go 1.24.0
 
 require (
-	github.com/google/uuid v1.3.0
-	golang.org/x/sys v0.0.0-20210615035016-665e8c7367d1 // indirect
-	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211 // indirect
+	github.com/andybalholm/brotli v1.0.6
+	github.com/google/uuid v1.6.0
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/crypto v0.48.0
+	golang.org/x/term v0.40.0
+	google.golang.org/grpc v1.75.1
+	google.golang.org/protobuf v1.36.6
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/net v0.49.0 // indirect
+	golang.org/x/sys v0.41.0 // indirect
+	golang.org/x/text v0.34.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
 )
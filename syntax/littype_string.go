@@ -13,11 +13,12 @@ func _() {
 	_ = x[FloatLit-3]
 	_ = x[DurationLit-4]
 	_ = x[BoolLit-5]
+	_ = x[RegexLit-6]
 }
 
-const _LitType_name = "stringintfloatdurationbool"
+const _LitType_name = "stringintfloatdurationboolregex"
 
-var _LitType_index = [...]uint8{0, 6, 9, 14, 22, 26}
+var _LitType_index = [...]uint8{0, 6, 9, 14, 22, 26, 31}
 
 func (i LitType) String() string {
 	i -= 1
@@ -116,6 +116,19 @@ type KeyExpr struct {
 	Value Node
 }
 
+// FuncLit for a function that is passed as an argument to a command. The
+// function is called with a single argument that is bound to the Param, and
+// evaluates to the Body. Any Stmts are executed beforehand.
+// (Param -> Body)
+// (Param -> { Stmts[0]; Stmts[1]; ... Body })
+type FuncLit struct {
+	node
+
+	Param *Name
+	Stmts []Node
+	Body  Node
+}
+
 // BlockStmt for a list of top-level statements, each separated by a semi.
 // {Nodes[0]; Nodes[1]; ...}
 type BlockStmt struct {
@@ -208,3 +221,18 @@ type Range struct {
 	Left  Node
 	Right Node
 }
+
+// PollStmt for repeatedly executing the Init statement every Interval until
+// the condition evaluates to a truthy value, or until the Timeout has passed.
+// The Body is executed after each attempt where the condition is not met.
+// poll Init; Cond; Interval Timeout
+// poll Init; Cond; Interval Timeout { Body }
+type PollStmt struct {
+	node
+
+	Init     Node
+	Cond     Node
+	Interval Node
+	Timeout  Node
+	Body     *BlockStmt
+}
@@ -309,21 +309,54 @@ func (p *parser) list(sep, end token, parse func()) {
 	p.want(end)
 }
 
-func (p *parser) obj() *Object {
+// paren parses either an object or a function literal. Both of these are
+// wrapped in a pair of ( ), the token following the first name determines
+// which is parsed.
+func (p *parser) paren() Node {
 	p.want(_Lparen)
 
+	nod := p.node()
+
+	if p.tok != _Name {
+		return p.obj(nod, nil)
+	}
+
+	name := p.name()
+
+	if p.got(_Arrow) {
+		return p.funcLit(nod, name)
+	}
+	return p.obj(nod, name)
+}
+
+// obj parses an object. The given key is the first key in the object if it
+// has already been parsed. Keys are either names, or string literals.
+func (p *parser) obj(nod node, key *Name) *Object {
 	n := &Object{
-		node: p.node(),
+		node: nod,
 	}
 
 	p.list(_Comma, _Rparen, func() {
-		if p.tok != _Name {
-			p.expected(_Name)
-			p.advance(_Rparen, _Semi)
-			return
+		if key == nil {
+			// Keys that are not valid names, such as @id, can be given as a
+			// string literal.
+			if p.tok == _Literal && p.typ == StringLit {
+				key = &Name{
+					node:  p.node(),
+					Value: p.lit,
+				}
+				p.next()
+			}
 		}
 
-		key := p.name()
+		if key == nil {
+			if p.tok != _Name {
+				p.expected(_Name)
+				p.advance(_Rparen, _Semi)
+				return
+			}
+			key = p.name()
+		}
 
 		p.want(_Colon)
 
@@ -332,10 +365,79 @@ func (p *parser) obj() *Object {
 			Key:   key,
 			Value: p.expr(),
 		})
+
+		key = nil
 	})
 	return n
 }
 
+// funcLit parses a function literal, the given name being the name of the
+// parameter. The body of the function is either a single expression, or a
+// block of statements where the final expression is the result of the
+// function.
+func (p *parser) funcLit(nod node, param *Name) *FuncLit {
+	n := &FuncLit{
+		node:  nod,
+		Param: param,
+	}
+
+	if !p.got(_Lbrace) {
+		n.Body = p.expr()
+		p.want(_Rparen)
+		return n
+	}
+
+	for p.tok != _Rbrace && p.tok != _EOF {
+		switch p.tok {
+		case _Name:
+			expr := p.nameExpr()
+
+			if p.tok == _Assign || p.tok == _Comma {
+				n.Stmts = append(n.Stmts, p.assignStmt(expr))
+				p.want(_Semi)
+				continue
+			}
+
+			name, ok := expr.(*Name)
+
+			if !ok {
+				p.errAt(expr.Pos(), "unassigned index expression")
+				p.advance(_Semi, _Rbrace)
+				continue
+			}
+
+			var x Node = p.command(name)
+
+			if p.got(_Arrow) {
+				x = p.chain(x.(*CommandStmt))
+			}
+
+			x = p.binaryExpr(x, 0)
+
+			if p.got(_Semi) && p.tok != _Rbrace {
+				n.Stmts = append(n.Stmts, x)
+				continue
+			}
+			n.Body = x
+		case _If, _For, _Match, _Poll:
+			n.Stmts = append(n.Stmts, p.stmt(false))
+			continue
+		default:
+			n.Body = p.expr()
+			p.got(_Semi)
+		}
+		break
+	}
+
+	if n.Body == nil {
+		p.err("expected expression at end of function")
+	}
+
+	p.want(_Rbrace)
+	p.want(_Rparen)
+	return n
+}
+
 func (p *parser) arr() *Array {
 	p.want(_Lbrack)
 
@@ -414,7 +516,7 @@ func (p *parser) matchstmt() *MatchStmt {
 
 	p.want(_Lbrace)
 
-	for p.tok != _Rbrace {
+	for p.tok != _Rbrace && p.tok != _EOF {
 		if p.tok == _Name {
 			if p.lit != "_" {
 				p.unexpected(_Name)
@@ -590,6 +692,49 @@ body:
 	return n
 }
 
+func (p *parser) pollstmt() *PollStmt {
+	nodpos := p.node()
+
+	if !p.got(_Poll) {
+		return nil
+	}
+
+	n := &PollStmt{
+		node: nodpos,
+		Init: p.simpleStmt(_Poll),
+	}
+
+	if !p.got(_Semi) {
+		p.err("expected poll condition")
+		p.advance(_Semi)
+		return nil
+	}
+
+	n.Cond = p.expr()
+
+	if n.Cond == nil {
+		p.errAt(n.Pos(), "missing condition in poll statement")
+		return n
+	}
+
+	p.want(_Semi)
+
+	if n.Interval = p.operand(); n.Interval == nil {
+		p.err("expected poll interval")
+		return n
+	}
+
+	if n.Timeout = p.operand(); n.Timeout == nil {
+		p.err("expected poll timeout")
+		return n
+	}
+
+	if p.tok == _Lbrace {
+		n.Body = p.blockstmt()
+	}
+	return n
+}
+
 func (p *parser) chain(cmd *CommandStmt) *ChainExpr {
 	n := &ChainExpr{
 		Commands: []*CommandStmt{cmd},
@@ -622,7 +767,7 @@ func (p *parser) operand() Node {
 	case _Ref:
 		n = p.ref()
 	case _Lparen:
-		n = p.obj()
+		n = p.paren()
 	case _Lbrack:
 		n = p.arr()
 	}
@@ -685,6 +830,17 @@ func (p *parser) command(name *Name) *CommandStmt {
 			continue
 		}
 
+		// Allow the match keyword to be used as an argument so it can be
+		// used as the name of a subcommand, for example regex match.
+		if p.tok == _Match {
+			n.Args = append(n.Args, &Name{
+				node:  p.node(),
+				Value: p.lit,
+			})
+			p.next()
+			continue
+		}
+
 		arg := p.operand()
 
 		if arg == nil {
@@ -774,6 +930,13 @@ func (p *parser) stmt(inRepl bool) Node {
 	case _For:
 		n = p.forstmt()
 		return n
+	case _Poll:
+		poll := p.pollstmt()
+
+		if poll != nil && poll.Body != nil {
+			return poll
+		}
+		n = poll
 	case _Ref:
 		if inRepl {
 			n = p.ref()
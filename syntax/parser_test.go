@@ -251,6 +251,64 @@ func checkForStmt(t *testing.T, expected, actual *ForStmt) {
 	}
 }
 
+func checkPollStmt(t *testing.T, expected, actual *PollStmt) {
+	if actual.Init == nil {
+		t.Errorf("%s - expected Init for PollStmt\n", actual.Pos())
+		return
+	}
+	checkNode(t, expected.Init, actual.Init)
+
+	if actual.Cond == nil {
+		t.Errorf("%s - expected Cond for PollStmt\n", actual.Pos())
+		return
+	}
+	checkNode(t, expected.Cond, actual.Cond)
+
+	if actual.Interval == nil {
+		t.Errorf("%s - expected Interval for PollStmt\n", actual.Pos())
+		return
+	}
+	checkNode(t, expected.Interval, actual.Interval)
+
+	if actual.Timeout == nil {
+		t.Errorf("%s - expected Timeout for PollStmt\n", actual.Pos())
+		return
+	}
+	checkNode(t, expected.Timeout, actual.Timeout)
+
+	if expected.Body != nil {
+		if actual.Body == nil {
+			t.Errorf("%s - expected Body for PollStmt\n", actual.Pos())
+			return
+		}
+		checkNode(t, expected.Body, actual.Body)
+	} else if actual.Body != nil {
+		t.Errorf("%s - unexpected Body for PollStmt\n", actual.Pos())
+	}
+}
+
+func checkFuncLit(t *testing.T, expected, actual *FuncLit) {
+	if expected.Param.Value != actual.Param.Value {
+		t.Errorf("%s - unexpected FuncLit.Param, expected=%q, got=%q\n", actual.Pos(), expected.Param.Value, actual.Param.Value)
+		return
+	}
+
+	if len(expected.Stmts) != len(actual.Stmts) {
+		t.Errorf("%s - unexpected FuncLit.Stmts length, expected=%d, got=%d\n", actual.Pos(), len(expected.Stmts), len(actual.Stmts))
+		return
+	}
+
+	for i, n := range actual.Stmts {
+		checkNode(t, expected.Stmts[i], n)
+	}
+
+	if actual.Body == nil {
+		t.Errorf("%s - expected Body for FuncLit\n", actual.Pos())
+		return
+	}
+	checkNode(t, expected.Body, actual.Body)
+}
+
 func checkIfStmt(t *testing.T, expected, actual *IfStmt) {
 	if expected.Cond != nil {
 		if actual.Cond == nil {
@@ -415,6 +473,22 @@ func checkNode(t *testing.T, expected, actual Node) {
 			return
 		}
 		checkForStmt(t, v, for_)
+	case *PollStmt:
+		poll, ok := actual.(*PollStmt)
+
+		if !ok {
+			t.Errorf("%s - unexpected node type, expected=%T, got=%T\n", actual.Pos(), v, actual)
+			return
+		}
+		checkPollStmt(t, v, poll)
+	case *FuncLit:
+		fn, ok := actual.(*FuncLit)
+
+		if !ok {
+			t.Errorf("%s - unexpected node type, expected=%T, got=%T\n", actual.Pos(), v, actual)
+			return
+		}
+		checkFuncLit(t, v, fn)
 	case *Range:
 		range_, ok := actual.(*Range)
 
@@ -788,6 +862,171 @@ func Test_ParseFor(t *testing.T) {
 	}
 }
 
+func Test_ParsePoll(t *testing.T) {
+	nn, err := ParseFile(filepath.Join("testdata", "poll.req"), errh(t))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	init := &AssignStmt{
+		Left: &ExprList{
+			Nodes: []Node{
+				&Name{Value: "Resp"},
+			},
+		},
+		Right: &ExprList{
+			Nodes: []Node{
+				&CommandStmt{
+					Name: &Name{Value: "send"},
+					Args: []Node{
+						&Ref{
+							Left: &Name{Value: "Req"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	cond := &Operation{
+		Op: EqOp,
+		Left: &Ref{
+			Left: &DotExpr{
+				Left:  &Name{Value: "Resp"},
+				Right: &Name{Value: "StatusCode"},
+			},
+		},
+		Right: &Lit{
+			Type:  IntLit,
+			Value: "200",
+		},
+	}
+
+	expected := []Node{
+		&PollStmt{
+			Init:     init,
+			Cond:     cond,
+			Interval: &Lit{Type: DurationLit, Value: "5s"},
+			Timeout:  &Lit{Type: DurationLit, Value: "1m"},
+		},
+		&PollStmt{
+			Init:     init,
+			Cond:     cond,
+			Interval: &Lit{Type: DurationLit, Value: "5s"},
+			Timeout: &Ref{
+				Left: &Name{Value: "Timeout"},
+			},
+			Body: &BlockStmt{},
+		},
+	}
+
+	if len(nn) != len(expected) {
+		t.Fatalf("node count mismatch, expected=%d, got=%d\n", len(expected), len(nn))
+	}
+
+	for i, n := range nn {
+		checkNode(t, expected[i], n)
+	}
+}
+
+func Test_ParseFunc(t *testing.T) {
+	nn, err := ParseFile(filepath.Join("testdata", "func.req"), errh(t))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := &Ref{
+		Left: &Name{Value: "Items"},
+	}
+
+	expected := []Node{
+		&CommandStmt{
+			Name: &Name{Value: "filter"},
+			Args: []Node{
+				&FuncLit{
+					Param: &Name{Value: "Item"},
+					Body: &Operation{
+						Op: EqOp,
+						Left: &Ref{
+							Left: &IndExpr{
+								Left:  &Name{Value: "Item"},
+								Right: &Lit{Type: StringLit, Value: "status"},
+							},
+						},
+						Right: &Lit{Type: StringLit, Value: "failed"},
+					},
+				},
+				items,
+			},
+		},
+		&CommandStmt{
+			Name: &Name{Value: "map"},
+			Args: []Node{
+				&FuncLit{
+					Param: &Name{Value: "S"},
+					Stmts: []Node{
+						&AssignStmt{
+							Left: &ExprList{
+								Nodes: []Node{
+									&Name{Value: "S"},
+								},
+							},
+							Right: &ExprList{
+								Nodes: []Node{
+									&CommandStmt{
+										Name: &Name{Value: "str"},
+										Args: []Node{
+											&Name{Value: "trim"},
+											&Ref{Left: &Name{Value: "S"}},
+										},
+									},
+								},
+							},
+						},
+					},
+					Body: &CommandStmt{
+						Name: &Name{Value: "str"},
+						Args: []Node{
+							&Name{Value: "lower"},
+							&Ref{Left: &Name{Value: "S"}},
+						},
+					},
+				},
+				items,
+			},
+		},
+		&AssignStmt{
+			Left: &ExprList{
+				Nodes: []Node{
+					&Name{Value: "Obj"},
+				},
+			},
+			Right: &ExprList{
+				Nodes: []Node{
+					&Object{
+						Pairs: []*KeyExpr{
+							{
+								Key:   &Name{Value: "Item"},
+								Value: &Lit{Type: IntLit, Value: "1"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if len(nn) != len(expected) {
+		t.Fatalf("node count mismatch, expected=%d, got=%d\n", len(expected), len(nn))
+	}
+
+	for i, n := range nn {
+		checkNode(t, expected[i], n)
+	}
+}
+
 func Test_ParseAssign(t *testing.T) {
 	nn, err := ParseFile(filepath.Join("testdata", "assign.req"), errh(t))
 
@@ -946,6 +1185,29 @@ func Test_ParseAssign(t *testing.T) {
 				},
 			},
 		},
+		&AssignStmt{
+			Left: &ExprList{
+				Nodes: []Node{
+					&Name{Value: "Attrs"},
+				},
+			},
+			Right: &ExprList{
+				Nodes: []Node{
+					&Object{
+						Pairs: []*KeyExpr{
+							{
+								Key:   &Name{Value: "@id"},
+								Value: &Lit{Type: IntLit, Value: "1"},
+							},
+							{
+								Key:   &Name{Value: "Name"},
+								Value: &Lit{Type: StringLit, Value: "a"},
+							},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	if len(nn) != len(expected) {
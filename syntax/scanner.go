@@ -2,6 +2,8 @@ package syntax
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 	"unicode"
 )
 
@@ -150,6 +152,43 @@ func (sc *scanner) string() {
 	sc.lit = lit[1 : len(lit)-1]
 }
 
+// regex scans a regular expression literal that is delimited by a pair of
+// slashes. A slash within the literal can be escaped with a backslash.
+func (sc *scanner) regex() {
+	sc.startLit()
+
+	r := sc.get()
+
+	for r != '/' {
+		if r == '\\' {
+			sc.get()
+			r = sc.get()
+			continue
+		}
+		if r == '\n' || r == -1 {
+			sc.err("unexpected newline in regex")
+			break
+		}
+		r = sc.get()
+	}
+
+	lit := sc.stopLit()
+
+	sc.tok = _Literal
+	sc.typ = RegexLit
+	sc.lit = ""
+
+	if len(lit) < 2 {
+		return
+	}
+
+	sc.lit = strings.Replace(lit[1:len(lit)-1], `\/`, "/", -1)
+
+	if _, err := regexp.Compile(sc.lit); err != nil {
+		sc.err("invalid regex: " + err.Error())
+	}
+}
+
 func (sc *scanner) next() {
 redo:
 	sc.op = Op(0)
@@ -264,6 +303,8 @@ redo:
 		sc.tok = _Ref
 	case '"':
 		sc.string()
+	case '/':
+		sc.regex()
 	case '-':
 		if sc.get() == '>' {
 			sc.tok = _Arrow
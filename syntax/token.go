@@ -96,6 +96,7 @@ const (
 	_For      // for
 	_Match    // match
 	_Range    // range
+	_Poll     // poll
 )
 
 type LitType uint
@@ -107,6 +108,7 @@ const (
 	FloatLit                       // float
 	DurationLit                    // duration
 	BoolLit                        // bool
+	RegexLit                       // regex
 )
 
 var keywords = map[string]token{
@@ -117,6 +119,7 @@ var keywords = map[string]token{
 	"for":      _For,
 	"match":    _Match,
 	"range":    _Range,
+	"poll":     _Poll,
 }
 
 func lookupTok(s string) token {
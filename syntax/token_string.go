@@ -32,11 +32,12 @@ func _() {
 	_ = x[_For-22]
 	_ = x[_Match-23]
 	_ = x[_Range-24]
+	_ = x[_Poll-25]
 }
 
-const _token_name = "eofnameliteralopsemi or newline,:.->=${}))[]breakcontinueifelseformatchrange"
+const _token_name = "eofnameliteralopsemi or newline,:.->=${}))[]breakcontinueifelseformatchrangepoll"
 
-var _token_index = [...]uint8{0, 3, 7, 14, 16, 31, 32, 33, 34, 36, 37, 38, 39, 40, 41, 42, 43, 44, 49, 57, 59, 63, 66, 71, 76}
+var _token_index = [...]uint8{0, 3, 7, 14, 16, 31, 32, 33, 34, 36, 37, 38, 39, 40, 41, 42, 43, 44, 49, 57, 59, 63, 66, 71, 76, 80}
 
 func (i token) String() string {
 	i -= 1
@@ -47,15 +47,79 @@ func NewArray(items []Value) (*Array, error) {
 	return v, nil
 }
 
+// ToArray attempts to type assert the given value to an array.
+func ToArray(v Value) (*Array, error) {
+	a, ok := v.(*Array)
+
+	if !ok {
+		return nil, typeError(v.valueType(), arrayType)
+	}
+	return a, nil
+}
+
+// CopyArray returns a new array of the given items. Unlike NewArray, no type
+// check is performed, since the items are expected to have come from an
+// existing array.
+func CopyArray(items []Value) *Array {
+	a := &Array{
+		set:   make(map[uint32]struct{}),
+		Items: make([]Value, len(items)),
+	}
+
+	copy(a.Items, items)
+
+	a.hashItems()
+
+	return a
+}
+
 // hashItems hashes the string representation of each item in the array and
 // stores it in a table. This hash is performed using 32-bit FNV-1a hash.
 func (a *Array) hashItems() {
 	for _, it := range a.Items {
-		h := fnv.New32a()
-		h.Write([]byte(it.String()))
+		a.hashItem(it)
+	}
+}
+
+func (a *Array) hashItem(v Value) {
+	h := fnv.New32a()
+	h.Write([]byte(v.String()))
+
+	a.set[h.Sum32()] = struct{}{}
+}
 
-		a.set[h.Sum32()] = struct{}{}
+// Append appends the given values to the array. Each value must be of the
+// same type as the items already in the array.
+func (a *Array) Append(vals ...Value) error {
+	for _, val := range vals {
+		if len(a.Items) > 0 {
+			if err := CompareType(val, a.Items[0]); err != nil {
+				return err
+			}
+		}
+
+		if a.set == nil {
+			a.set = make(map[uint32]struct{})
+		}
+
+		a.Items = append(a.Items, val)
+		a.hashItem(val)
 	}
+	return nil
+}
+
+// Slice returns a new array of the items between the indexes i and j.
+func (a *Array) Slice(i, j int) *Array {
+	return CopyArray(a.Items[i:j])
+}
+
+// Delete returns a new array with the item at the given index removed.
+func (a *Array) Delete(i int) *Array {
+	items := make([]Value, 0, len(a.Items))
+	items = append(items, a.Items[:i]...)
+	items = append(items, a.Items[i+1:]...)
+
+	return CopyArray(items)
 }
 
 func (a *Array) MarshalJSON() ([]byte, error) {
@@ -96,13 +160,7 @@ func (a *Array) Get(v Value) (Value, error) {
 // Set sets the value at the given key with the given value.
 func (a *Array) Set(_ bool, key, val Value) error {
 	if _, ok := key.(*Array); ok {
-		if len(a.Items) > 0 {
-			if err := CompareType(val, a.Items[0]); err != nil {
-				return err
-			}
-		}
-		a.Items = append(a.Items, val)
-		return nil
+		return a.Append(val)
 	}
 
 	i64, err := ToInt(key)
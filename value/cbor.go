@@ -0,0 +1,476 @@
+package value
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// CBOR major types.
+const (
+	cborUint byte = iota
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// cborBreak is the stop code for indefinite length items.
+const cborBreak = 0xff
+
+type cborDecoder struct {
+	binReader
+}
+
+// head reads the head of the next data item, returning its major type, and
+// its argument. If the item has an indefinite length, then indef is true.
+func (d *cborDecoder) head() (major byte, arg uint64, indef bool, err error) {
+	b, err := d.byte()
+
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	major, info := b>>5, b&0x1f
+
+	switch {
+	case info < 24:
+		return major, uint64(info), false, nil
+	case info <= 27:
+		arg, err = d.uint(1 << (info - 24))
+		return major, arg, false, err
+	case info == 31:
+		return major, 0, true, nil
+	}
+	return 0, 0, false, errors.New("invalid cbor additional information " + strconv.Itoa(int(info)))
+}
+
+// atBreak reports whether the next byte is the break stop code, and consumes
+// it if so.
+func (d *cborDecoder) atBreak() bool {
+	if d.pos < len(d.b) && d.b[d.pos] == cborBreak {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+// chunks reads the data of a byte or text string, joining the chunks of an
+// indefinite length string.
+func (d *cborDecoder) chunks(major byte, n uint64, indef bool) ([]byte, error) {
+	if !indef {
+		if n > uint64(len(d.b)) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		p, err := d.next(int(n))
+
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, p...), nil
+	}
+
+	var buf bytes.Buffer
+
+	for !d.atBreak() {
+		m, n, indef, err := d.head()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if m != major || indef {
+			return nil, errors.New("invalid chunk in indefinite length cbor string")
+		}
+
+		p, err := d.chunks(major, n, false)
+
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(p)
+	}
+	return buf.Bytes(), nil
+}
+
+// float16 returns the float64 for the given half-precision float.
+func float16(h uint16) float64 {
+	sign, exp, frac := h>>15, int(h>>10)&0x1f, float64(h&0x3ff)
+
+	var f float64
+
+	switch exp {
+	case 0:
+		f = math.Ldexp(frac, -24)
+	case 0x1f:
+		f = math.Inf(1)
+
+		if frac != 0 {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(frac+1024, exp-25)
+	}
+
+	if sign != 0 {
+		return -f
+	}
+	return f
+}
+
+// bignum returns the value for the given bignum. Bignums that fit into an Int
+// are returned as an Int, otherwise they are returned as a Float that keeps
+// the exact digits.
+func bignum(n *big.Int) Value {
+	if n.IsInt64() {
+		return Int{Value: n.Int64()}
+	}
+
+	f, _ := new(big.Float).SetInt(n).Float64()
+
+	return Float{Value: f, digits: n.String()}
+}
+
+// tag decodes the data item for the given tag. Times and bignums are decoded
+// into their respective values, the content of any other tag is decoded as
+// is.
+func (d *cborDecoder) tag(num uint64) (Value, error) {
+	switch num {
+	case 2, 3:
+		major, n, indef, err := d.head()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if major != cborBytes {
+			return nil, errors.New("invalid cbor bignum")
+		}
+
+		b, err := d.chunks(major, n, indef)
+
+		if err != nil {
+			return nil, err
+		}
+
+		i := new(big.Int).SetBytes(b)
+
+		if num == 3 {
+			i.Neg(i).Sub(i, big.NewInt(1))
+		}
+		return bignum(i), nil
+	}
+
+	val, err := d.decode()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch num {
+	case 0:
+		s, ok := val.(String)
+
+		if !ok {
+			return nil, errors.New("invalid cbor date/time string")
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, s.Value)
+
+		if err != nil {
+			return nil, err
+		}
+		return Time{Value: t}, nil
+	case 1:
+		switch v := val.(type) {
+		case Int:
+			return Time{Value: time.Unix(v.Value, 0).UTC()}, nil
+		case Float:
+			sec, frac := math.Modf(v.Value)
+			return Time{Value: time.Unix(int64(sec), int64(frac*1e9)).UTC()}, nil
+		}
+		return nil, errors.New("invalid cbor epoch date/time")
+	}
+	return val, nil
+}
+
+func (d *cborDecoder) decode() (Value, error) {
+	start := d.pos
+
+	major, arg, indef, err := d.head()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if indef && major != cborBytes && major != cborText && major != cborArray && major != cborMap {
+		return nil, errors.New("unexpected indefinite length cbor item")
+	}
+
+	switch major {
+	case cborUint:
+		return uintValue(arg), nil
+	case cborNegInt:
+		if arg > math.MaxInt64 {
+			i := new(big.Int).SetUint64(arg)
+			return bignum(i.Neg(i).Sub(i, big.NewInt(1))), nil
+		}
+		return Int{Value: -1 - int64(arg)}, nil
+	case cborBytes:
+		b, err := d.chunks(major, arg, indef)
+
+		if err != nil {
+			return nil, err
+		}
+		return bytesStream(b), nil
+	case cborText:
+		b, err := d.chunks(major, arg, indef)
+
+		if err != nil {
+			return nil, err
+		}
+		return String{Value: string(b)}, nil
+	case cborArray:
+		items := make([]Value, 0)
+
+		for i := uint64(0); indef || i < arg; i++ {
+			if indef && d.atBreak() {
+				break
+			}
+
+			val, err := d.decode()
+
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, val)
+		}
+		return CopyArray(items), nil
+	case cborMap:
+		obj := &Object{
+			Order: make([]string, 0),
+			Pairs: make(map[string]Value),
+		}
+
+		for i := uint64(0); indef || i < arg; i++ {
+			if indef && d.atBreak() {
+				break
+			}
+
+			key, err := d.decode()
+
+			if err != nil {
+				return nil, err
+			}
+
+			k, err := binaryKey(key)
+
+			if err != nil {
+				return nil, err
+			}
+
+			val, err := d.decode()
+
+			if err != nil {
+				return nil, err
+			}
+
+			if _, ok := obj.Pairs[k]; !ok {
+				obj.Order = append(obj.Order, k)
+			}
+			obj.Pairs[k] = val
+		}
+		return obj, nil
+	case cborTag:
+		return d.tag(arg)
+	}
+
+	// Major type 7, where the argument is either a simple value, or the bits
+	// of a float.
+	switch d.b[start] & 0x1f {
+	case 20:
+		return Bool{Value: false}, nil
+	case 21:
+		return Bool{Value: true}, nil
+	case 22, 23:
+		return Zero{}, nil
+	case 25:
+		return Float{Value: float16(uint16(arg))}, nil
+	case 26:
+		return Float{Value: float64(math.Float32frombits(uint32(arg)))}, nil
+	case 27:
+		return Float{Value: math.Float64frombits(arg)}, nil
+	}
+	return nil, errors.New("unsupported cbor simple value " + strconv.FormatUint(arg, 10))
+}
+
+// DecodeCBOR decodes the CBOR data item in the given reader. Maps are decoded
+// to objects with their keys in the order they appear, byte strings to a
+// stream, and date/time tags to a time.
+func DecodeCBOR(r io.Reader) (Value, error) {
+	b, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d := &cborDecoder{
+		binReader: binReader{b: b},
+	}
+
+	val, err := d.decode()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if d.pos != len(b) {
+		return nil, errors.New("unexpected data after cbor data item")
+	}
+	return val, nil
+}
+
+// cborHead writes the head of a data item with the given major type and
+// argument, using the smallest encoding for the argument.
+func cborHead(buf *bytes.Buffer, major byte, arg uint64) {
+	major <<= 5
+
+	switch {
+	case arg < 24:
+		buf.WriteByte(major | byte(arg))
+	case arg <= math.MaxUint8:
+		buf.WriteByte(major | 24)
+		putUint(buf, arg, 1)
+	case arg <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		putUint(buf, arg, 2)
+	case arg <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		putUint(buf, arg, 4)
+	default:
+		buf.WriteByte(major | 27)
+		putUint(buf, arg, 8)
+	}
+}
+
+func encodeCborInt(buf *bytes.Buffer, i int64) {
+	if i < 0 {
+		cborHead(buf, cborNegInt, uint64(-1-i))
+		return
+	}
+	cborHead(buf, cborUint, uint64(i))
+}
+
+func encodeCborFloat(buf *bytes.Buffer, f float64) {
+	buf.WriteByte(cborSimple<<5 | 27)
+	putUint(buf, math.Float64bits(f), 8)
+}
+
+func encodeCbor(buf *bytes.Buffer, v Value) error {
+	switch v := v.(type) {
+	case *Object:
+		keys := v.keys()
+
+		cborHead(buf, cborMap, uint64(len(keys)))
+
+		for _, k := range keys {
+			cborHead(buf, cborText, uint64(len(k)))
+			buf.WriteString(k)
+
+			if err := encodeCbor(buf, v.Pairs[k]); err != nil {
+				return err
+			}
+		}
+	case *Array:
+		cborHead(buf, cborArray, uint64(len(v.Items)))
+
+		for _, it := range v.Items {
+			if err := encodeCbor(buf, it); err != nil {
+				return err
+			}
+		}
+	case String:
+		cborHead(buf, cborText, uint64(len(v.Value)))
+		buf.WriteString(v.Value)
+	case Int:
+		encodeCborInt(buf, v.Value)
+	case Float:
+		if v.digits != "" {
+			if i, ok := new(big.Int).SetString(v.digits, 10); ok {
+				if i.IsUint64() {
+					cborHead(buf, cborUint, i.Uint64())
+					return nil
+				}
+
+				num := uint64(2)
+
+				if i.Sign() < 0 {
+					num = 3
+					i.Neg(i).Sub(i, big.NewInt(1))
+				}
+
+				b := i.Bytes()
+
+				cborHead(buf, cborTag, num)
+				cborHead(buf, cborBytes, uint64(len(b)))
+				buf.Write(b)
+				return nil
+			}
+		}
+		encodeCborFloat(buf, v.Value)
+	case Bool:
+		if v.Value {
+			buf.WriteByte(cborSimple<<5 | 21)
+			return nil
+		}
+		buf.WriteByte(cborSimple<<5 | 20)
+	case Time:
+		// Encode times as epoch based date/times, using a float if there
+		// are fractional seconds.
+		cborHead(buf, cborTag, 1)
+
+		if v.Value.Nanosecond() == 0 {
+			encodeCborInt(buf, v.Value.Unix())
+			return nil
+		}
+		encodeCborFloat(buf, float64(v.Value.UnixNano())/1e9)
+	case Duration:
+		s := v.Value.String()
+
+		cborHead(buf, cborText, uint64(len(s)))
+		buf.WriteString(s)
+	case Stream:
+		b, err := readStream(v)
+
+		if err != nil {
+			return err
+		}
+
+		cborHead(buf, cborBytes, uint64(len(b)))
+		buf.Write(b)
+	case Zero:
+		buf.WriteByte(cborSimple<<5 | 22)
+	default:
+		return errors.New("cannot encode " + v.valueType().String() + " to cbor")
+	}
+	return nil
+}
+
+// EncodeCBOR encodes the given value to CBOR. Streams are encoded as byte
+// strings, and times as epoch based date/times.
+func EncodeCBOR(v Value) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := encodeCbor(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
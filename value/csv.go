@@ -0,0 +1,204 @@
+package value
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+)
+
+// CSVOptions configures how CSV is encoded and decoded.
+type CSVOptions struct {
+	Comma  rune // The delimiter between fields.
+	Header bool // Whether the first record is a header.
+	Lazy   bool // Whether a stream is decoded into an iterator.
+}
+
+type csvDecoder struct {
+	r      *csv.Reader
+	header []string
+	opts   CSVOptions
+}
+
+// utf8BOM is the byte order mark that some spreadsheet software writes at the
+// start of a CSV file.
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+func newCSVDecoder(r io.Reader, opts CSVOptions) *csvDecoder {
+	br := bufio.NewReader(r)
+
+	if b, _ := br.Peek(len(utf8BOM)); bytes.Equal(b, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+
+	cr := csv.NewReader(br)
+	cr.Comma = opts.Comma
+
+	return &csvDecoder{
+		r:    cr,
+		opts: opts,
+	}
+}
+
+// next decodes the next record. If the decoder has a header, then the record
+// is decoded into an object keyed by the header, otherwise it is decoded into
+// an array of strings.
+func (d *csvDecoder) next() (Value, error) {
+	if d.opts.Header && d.header == nil {
+		header, err := d.r.Read()
+
+		if err != nil {
+			return nil, err
+		}
+		d.header = header
+	}
+
+	rec, err := d.r.Read()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if d.header == nil {
+		items := make([]Value, 0, len(rec))
+
+		for _, s := range rec {
+			items = append(items, String{Value: s})
+		}
+		return CopyArray(items), nil
+	}
+
+	obj := &Object{
+		Order: make([]string, 0, len(d.header)),
+		Pairs: make(map[string]Value),
+	}
+
+	for i, k := range d.header {
+		if _, ok := obj.Pairs[k]; !ok {
+			obj.Order = append(obj.Order, k)
+		}
+		obj.Pairs[k] = String{Value: rec[i]}
+	}
+	return obj, nil
+}
+
+// DecodeCSV decodes all of the records in the given reader into an array.
+func DecodeCSV(r io.Reader, opts CSVOptions) (Value, error) {
+	dec := newCSVDecoder(r, opts)
+
+	items := make([]Value, 0)
+
+	for {
+		val, err := dec.next()
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		items = append(items, val)
+	}
+	return CopyArray(items), nil
+}
+
+// IterateCSV returns an iterator that decodes each record in the given stream
+// as it is iterated over.
+func IterateCSV(s Stream, opts CSVOptions) *Iterator {
+	return StreamIterator(s, newCSVDecoder(s, opts).next)
+}
+
+// csvField returns the field for the given value.
+func csvField(v Value) (string, error) {
+	s, ok := scalarText(v)
+
+	if !ok {
+		return "", errors.New("cannot encode " + v.valueType().String() + " to csv field")
+	}
+	return s, nil
+}
+
+// EncodeCSV encodes the given array of either objects or arrays to CSV. For an
+// array of objects, the header is made up of the keys of each object in the
+// order they are first seen, and is only written if opts.Header is true.
+func EncodeCSV(v Value, opts CSVOptions) ([]byte, error) {
+	arr, err := ToArray(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+	w.Comma = opts.Comma
+
+	header := make([]string, 0)
+	seen := make(map[string]struct{})
+
+	for _, it := range arr.Items {
+		obj, ok := it.(*Object)
+
+		if !ok {
+			continue
+		}
+
+		for _, k := range obj.keys() {
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				header = append(header, k)
+			}
+		}
+	}
+
+	if opts.Header && len(header) > 0 {
+		if err := w.Write(header); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, it := range arr.Items {
+		var vals []Value
+
+		switch it := it.(type) {
+		case *Object:
+			vals = make([]Value, 0, len(header))
+
+			for _, k := range header {
+				val, ok := it.Pairs[k]
+
+				if !ok {
+					val = Zero{}
+				}
+				vals = append(vals, val)
+			}
+		case *Array:
+			vals = it.Items
+		default:
+			return nil, errors.New("cannot encode " + it.valueType().String() + " to csv record")
+		}
+
+		rec := make([]string, 0, len(vals))
+
+		for _, val := range vals {
+			s, err := csvField(val)
+
+			if err != nil {
+				return nil, err
+			}
+			rec = append(rec, s)
+		}
+
+		if err := w.Write(rec); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
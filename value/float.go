@@ -3,19 +3,36 @@ package value
 import (
 	"encoding/json"
 	"strconv"
+	"strings"
 
 	"github.com/andrewpillar/req/syntax"
 )
 
+// Float is the value for float types. A float may also hold a whole number
+// that was too large to fit in an Int. The original digits of a decoded number
+// are kept, so it is encoded again exactly as it was written.
 type Float struct {
 	Value float64
+
+	digits string
+}
+
+// whole reports whether the float holds the digits of a whole number.
+func (f Float) whole() bool {
+	return f.digits != "" && !strings.ContainsAny(f.digits, ".eE")
 }
 
 func (f Float) MarshalJSON() ([]byte, error) {
+	if f.digits != "" {
+		return []byte(f.digits), nil
+	}
 	return json.Marshal(f.Value)
 }
 
 func (f Float) String() string {
+	if f.whole() {
+		return f.digits
+	}
 	return strconv.FormatFloat(f.Value, 'f', 2, 64)
 }
 
@@ -30,7 +47,7 @@ func (f Float) valueType() valueType {
 func (f Float) cmp(op syntax.Op, b Value) (Value, error) {
 	typ := b.valueType()
 
-	if typ != intType {
+	if typ != floatType {
 		if typ != zeroType {
 			return nil, compareError(op, f, b)
 		}
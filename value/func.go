@@ -0,0 +1,36 @@
+package value
+
+import "github.com/andrewpillar/req/syntax"
+
+// Func is the value for a function literal. Functions are passed as arguments
+// to commands, and are called with a single value.
+type Func struct {
+	Param string
+	Call  func(v Value) (Value, error)
+}
+
+// ToFunc attempts to type assert the given value to a func.
+func ToFunc(v Value) (Func, error) {
+	fn, ok := v.(Func)
+
+	if !ok {
+		return Func{}, typeError(v.valueType(), funcType)
+	}
+	return fn, nil
+}
+
+func (f Func) String() string {
+	return "(" + f.Param + " -> ...)"
+}
+
+func (f Func) Sprint() string {
+	return f.String()
+}
+
+func (f Func) valueType() valueType {
+	return funcType
+}
+
+func (f Func) cmp(op syntax.Op, _ Value) (Value, error) {
+	return nil, opError(op, funcType)
+}
@@ -0,0 +1,98 @@
+package value
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andrewpillar/req/syntax"
+)
+
+// Iterator is a value that lazily produces a sequence of values, such as the
+// records decoded from a stream. An iterator can only be iterated over once.
+type Iterator struct {
+	i    int64
+	next func() (Value, error)
+	stop func() error
+	err  error
+}
+
+// NewIterator returns an iterator that calls the given function for each
+// value. The function should return io.EOF once there are no more values.
+func NewIterator(next func() (Value, error)) *Iterator {
+	return &Iterator{
+		next: next,
+	}
+}
+
+// StreamIterator returns an iterator that calls the given function for each
+// value read from the given stream. The stream is rewound once the iterator is
+// stopped, either by reaching the end of the stream, or by calling Stop.
+func StreamIterator(s Stream, next func() (Value, error)) *Iterator {
+	it := NewIterator(next)
+	it.stop = func() error {
+		_, err := s.Seek(0, io.SeekStart)
+		return err
+	}
+	return it
+}
+
+// Stop stops the iterator, so any subsequent call to Next returns io.EOF, or
+// the error the iterator has already returned. This should be called when
+// iteration ends early, so anything the iterator holds onto can be released.
+func (it *Iterator) Stop() error {
+	if it.err == nil {
+		it.err = io.EOF
+	}
+
+	if it.stop != nil {
+		stop := it.stop
+		it.stop = nil
+
+		return stop()
+	}
+	return nil
+}
+
+// Next returns the index and value of the next item in the iterator. Once the
+// iterator has returned an error, that same error is returned for each
+// subsequent call.
+func (it *Iterator) Next() (Value, Value, error) {
+	if it.err != nil {
+		return nil, nil, it.err
+	}
+
+	val, err := it.next()
+
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			if err := it.Stop(); err != nil {
+				it.err = err
+				return nil, nil, err
+			}
+		}
+		it.err = err
+		return nil, nil, err
+	}
+
+	key := Int{Value: it.i}
+	it.i++
+
+	return key, val, nil
+}
+
+func (it *Iterator) String() string {
+	return fmt.Sprintf("Iterator<addr=%p>", it)
+}
+
+func (it *Iterator) Sprint() string {
+	return it.String()
+}
+
+func (it *Iterator) valueType() valueType {
+	return iteratorType
+}
+
+func (it *Iterator) cmp(op syntax.Op, _ Value) (Value, error) {
+	return nil, opError(op, iteratorType)
+}
@@ -1,60 +1,341 @@
 package value
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
 )
 
-func decodeJson(a interface{}) Value {
-	var val Value = Zero{}
+// decodeNumber decodes the given JSON number. Whole numbers without a fraction
+// or exponent are decoded as an Int. Any other number is decoded as a Float
+// that keeps the original digits, so it is not changed when encoded again,
+// and the same goes for whole numbers too large to fit in an Int. Numbers
+// outside the range of a float64 are an error.
+func decodeNumber(n json.Number) (Value, error) {
+	s := n.String()
 
-	switch v := a.(type) {
-	case string:
-		val = String{Value: v}
-	case float64:
-		if v == float64(int64(v)) {
-			val = Int{Value: int64(v)}
-			break
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return Int{Value: i}, nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+
+	if err != nil {
+		// Only an out of range error can be returned at this point, since
+		// the number has already been validated by the decoder.
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, errors.New("number " + s + " out of range")
 		}
-		val = Float{Value: v}
+		return nil, err
+	}
+	return Float{Value: f, digits: s}, nil
+}
+
+// decodeJson decodes the next JSON value from the given decoder. Objects are
+// decoded with their keys in the order they appear in the source.
+func decodeJson(dec *json.Decoder) (Value, error) {
+	tok, err := dec.Token()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := tok.(type) {
+	case json.Delim:
+		switch v {
+		case '[':
+			arr := &Array{
+				set:   make(map[uint32]struct{}),
+				Items: make([]Value, 0),
+			}
+
+			for dec.More() {
+				val, err := decodeJson(dec)
+
+				if err != nil {
+					return nil, err
+				}
+				arr.Items = append(arr.Items, val)
+			}
+
+			// Consume the closing ].
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+
+			arr.hashItems()
+
+			return arr, nil
+		case '{':
+			obj := &Object{
+				Order: make([]string, 0),
+				Pairs: make(map[string]Value),
+			}
+
+			for dec.More() {
+				tok, err := dec.Token()
+
+				if err != nil {
+					return nil, err
+				}
+
+				key, ok := tok.(string)
+
+				if !ok {
+					return nil, errors.New("invalid object key")
+				}
+
+				val, err := decodeJson(dec)
+
+				if err != nil {
+					return nil, err
+				}
+
+				// Duplicate keys take the last value, but keep the
+				// position of the first.
+				if _, ok := obj.Pairs[key]; !ok {
+					obj.Order = append(obj.Order, key)
+				}
+				obj.Pairs[key] = val
+			}
+
+			// Consume the closing }.
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return obj, nil
+		}
+		return nil, errors.New("unexpected " + v.String())
+	case string:
+		return String{Value: v}, nil
+	case json.Number:
+		return decodeNumber(v)
 	case bool:
-		val = Bool{Value: v}
-	case []interface{}:
-		arr := &Array{
-			set:   make(map[uint32]struct{}),
-			Items: make([]Value, 0, len(v)),
+		return Bool{Value: v}, nil
+	}
+	return Zero{}, nil
+}
+
+// DecodeJSON attempts to decode the next JSON value in the given reader. The
+// returned value will either be of type String, Int, Float, Bool, Array,
+// Object, or Zero depending on the JSON being decoded. The keys of decoded
+// objects are kept in the order they appear in the JSON, and integers are kept
+// exact.
+func DecodeJSON(r io.Reader) (Value, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	return decodeJson(dec)
+}
+
+// JSONOptions configures how values are encoded to JSON.
+type JSONOptions struct {
+	// Indent is the string used for each level of indentation. If empty,
+	// then the JSON is compact.
+	Indent string
+
+	// SortKeys encodes the keys of objects in sorted order instead of the
+	// order in which they were defined.
+	SortKeys bool
+
+	// EscapeHTML escapes the <, >, and & characters in strings.
+	EscapeHTML bool
+
+	// Canonical encodes the value as canonical JSON as described in RFC
+	// 8785. This overrides all other options.
+	Canonical bool
+}
+
+// EncodeJSON encodes the given value to JSON with the given options.
+func EncodeJSON(v Value, opts JSONOptions) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if opts.Canonical {
+		opts = JSONOptions{Canonical: true}
+	}
+
+	if err := encodeJson(&buf, v, opts); err != nil {
+		return nil, err
+	}
+
+	if opts.Indent == "" {
+		return buf.Bytes(), nil
+	}
+
+	var indented bytes.Buffer
+
+	if err := json.Indent(&indented, buf.Bytes(), "", opts.Indent); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+// lessUTF16 reports whether a sorts before b when comparing their UTF-16 code
+// units, as required for sorting keys in canonical JSON.
+func lessUTF16(a, b string) bool {
+	u1, u2 := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+
+	for i := 0; i < len(u1) && i < len(u2); i++ {
+		if u1[i] != u2[i] {
+			return u1[i] < u2[i]
+		}
+	}
+	return len(u1) < len(u2)
+}
+
+func encodeJson(buf *bytes.Buffer, v Value, opts JSONOptions) error {
+	switch v := v.(type) {
+	case *Object:
+		keys := v.keys()
+
+		if opts.SortKeys || opts.Canonical {
+			keys = append([]string{}, keys...)
+
+			if opts.Canonical {
+				sort.Slice(keys, func(i, j int) bool {
+					return lessUTF16(keys[i], keys[j])
+				})
+			} else {
+				sort.Strings(keys)
+			}
 		}
 
-		for _, a := range v {
-			arr.Items = append(arr.Items, decodeJson(a))
+		buf.WriteByte('{')
+
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			if err := encodeJsonString(buf, k, opts); err != nil {
+				return err
+			}
+
+			buf.WriteByte(':')
+
+			if err := encodeJson(buf, v.Pairs[k], opts); err != nil {
+				return err
+			}
 		}
+		buf.WriteByte('}')
+	case *Array:
+		buf.WriteByte('[')
 
-		arr.hashItems()
+		for i, it := range v.Items {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
 
-		val = arr
-	case map[string]interface{}:
-		obj := &Object{
-			Order: make([]string, 0, len(v)),
-			Pairs: make(map[string]Value),
+			if err := encodeJson(buf, it, opts); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case String:
+		return encodeJsonString(buf, v.Value, opts)
+	default:
+		if f, ok := v.(Float); ok && opts.Canonical && !f.whole() {
+			buf.WriteString(formatES6(f.Value))
+			return nil
 		}
 
-		for k, a := range v {
-			obj.Order = append(obj.Order, k)
-			obj.Pairs[k] = decodeJson(a)
+		b, err := json.Marshal(v)
+
+		if err != nil {
+			return err
 		}
-		val = obj
+		buf.Write(b)
 	}
-	return val
+	return nil
 }
 
-// DecodeJSON attempts to decode all of the data in the given reader to JSON.
-// The returned value will either be of type String, Int, Bool, Array, Object,
-// or Zero depending on the JSON string being decoded.
-func DecodeJSON(r io.Reader) (Value, error) {
-	var p interface{}
+func encodeJsonString(buf *bytes.Buffer, s string, opts JSONOptions) error {
+	if opts.Canonical {
+		writeCanonicalString(buf, s)
+		return nil
+	}
 
-	if err := json.NewDecoder(r).Decode(&p); err != nil {
-		return nil, err
+	var tmp bytes.Buffer
+
+	enc := json.NewEncoder(&tmp)
+	enc.SetEscapeHTML(opts.EscapeHTML)
+
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+
+	// Drop the trailing newline written by the encoder.
+	buf.Write(bytes.TrimSuffix(tmp.Bytes(), []byte("\n")))
+	return nil
+}
+
+// writeCanonicalString writes the given string as a canonical JSON string.
+// Only the quote, backslash, and control characters are escaped.
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+
+	buf.WriteByte('"')
+
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[r>>4])
+				buf.WriteByte(hex[r&0xf])
+				continue
+			}
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// formatES6 formats the given float as per the Number.prototype.toString
+// algorithm in ECMAScript, as required for numbers in canonical JSON.
+func formatES6(f float64) string {
+	if f == 0 {
+		return "0"
+	}
+
+	abs := math.Abs(f)
+
+	if abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
 	}
-	return decodeJson(p), nil
+
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+
+	// Go formats the exponent with at least two digits, whereas ECMAScript
+	// uses as few as possible.
+	mant, exp := s, ""
+
+	if i := strings.IndexByte(s, 'e'); i >= 0 {
+		mant, exp = s[:i], s[i+1:]
+	}
+
+	sign := exp[0]
+	exp = strings.TrimLeft(exp[1:], "0")
+
+	return mant + "e" + string(sign) + exp
 }
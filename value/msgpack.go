@@ -0,0 +1,476 @@
+package value
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// binReader reads from a buffer of binary encoded data.
+type binReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *binReader) next(n int) ([]byte, error) {
+	if n < 0 || len(r.b)-r.pos < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	b := r.b[r.pos : r.pos+n]
+	r.pos += n
+
+	return b, nil
+}
+
+func (r *binReader) byte() (byte, error) {
+	b, err := r.next(1)
+
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// uint reads a big-endian unsigned integer of the given number of bytes.
+func (r *binReader) uint(n int) (uint64, error) {
+	b, err := r.next(n)
+
+	if err != nil {
+		return 0, err
+	}
+
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// uintValue returns the value for the given unsigned integer. Integers too
+// large for an Int are returned as a Float that keeps the exact digits.
+func uintValue(u uint64) Value {
+	if u > math.MaxInt64 {
+		s := strconv.FormatUint(u, 10)
+
+		return Float{Value: float64(u), digits: s}
+	}
+	return Int{Value: int64(u)}
+}
+
+// binaryKey returns the object key for the given decoded map key.
+func binaryKey(v Value) (string, error) {
+	s, ok := scalarText(v)
+
+	if !ok {
+		return "", errors.New("cannot use " + v.valueType().String() + " as object key")
+	}
+	return s, nil
+}
+
+// readStream reads all of the data from the given stream, and rewinds it.
+func readStream(s Stream) ([]byte, error) {
+	if _, err := s.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	b, err := io.ReadAll(s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func bytesStream(b []byte) Value {
+	return NewStream(BufferStream(bytes.NewReader(b)))
+}
+
+type msgpackDecoder struct {
+	binReader
+}
+
+func (d *msgpackDecoder) array(n int) (Value, error) {
+	items := make([]Value, 0)
+
+	for i := 0; i < n; i++ {
+		val, err := d.decode()
+
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, val)
+	}
+	return CopyArray(items), nil
+}
+
+func (d *msgpackDecoder) object(n int) (Value, error) {
+	obj := &Object{
+		Order: make([]string, 0),
+		Pairs: make(map[string]Value),
+	}
+
+	for i := 0; i < n; i++ {
+		key, err := d.decode()
+
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := binaryKey(key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		val, err := d.decode()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := obj.Pairs[k]; !ok {
+			obj.Order = append(obj.Order, k)
+		}
+		obj.Pairs[k] = val
+	}
+	return obj, nil
+}
+
+// ext decodes extension data of the given length. Only the timestamp
+// extension is supported.
+func (d *msgpackDecoder) ext(n int) (Value, error) {
+	typ, err := d.byte()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if int8(typ) != -1 {
+		return nil, errors.New("unsupported msgpack extension type " + strconv.Itoa(int(int8(typ))))
+	}
+
+	var sec, nsec uint64
+
+	switch n {
+	case 4:
+		sec, err = d.uint(4)
+	case 8:
+		var u uint64
+
+		u, err = d.uint(8)
+		nsec, sec = u>>34, u&(1<<34-1)
+	case 12:
+		if nsec, err = d.uint(4); err == nil {
+			sec, err = d.uint(8)
+		}
+	default:
+		return nil, errors.New("invalid msgpack timestamp length " + strconv.Itoa(n))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return Time{Value: time.Unix(int64(sec), int64(nsec)).UTC()}, nil
+}
+
+func (d *msgpackDecoder) decode() (Value, error) {
+	b, err := d.byte()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return Int{Value: int64(b)}, nil
+	case b >= 0xe0:
+		return Int{Value: int64(int8(b))}, nil
+	case b&0xf0 == 0x80:
+		return d.object(int(b & 0x0f))
+	case b&0xf0 == 0x90:
+		return d.array(int(b & 0x0f))
+	case b&0xe0 == 0xa0:
+		s, err := d.next(int(b & 0x1f))
+
+		if err != nil {
+			return nil, err
+		}
+		return String{Value: string(s)}, nil
+	}
+
+	// Sizes of the length prefix for each of the str, bin, array, map, and
+	// ext formats.
+	sizes := map[byte]int{
+		0xc4: 1, 0xc5: 2, 0xc6: 4,
+		0xc7: 1, 0xc8: 2, 0xc9: 4,
+		0xd9: 1, 0xda: 2, 0xdb: 4,
+		0xdc: 2, 0xdd: 4,
+		0xde: 2, 0xdf: 4,
+	}
+
+	if size, ok := sizes[b]; ok {
+		u, err := d.uint(size)
+
+		if err != nil {
+			return nil, err
+		}
+
+		n := int(u)
+
+		switch b {
+		case 0xc4, 0xc5, 0xc6:
+			p, err := d.next(n)
+
+			if err != nil {
+				return nil, err
+			}
+			return bytesStream(append([]byte{}, p...)), nil
+		case 0xc7, 0xc8, 0xc9:
+			return d.ext(n)
+		case 0xd9, 0xda, 0xdb:
+			s, err := d.next(n)
+
+			if err != nil {
+				return nil, err
+			}
+			return String{Value: string(s)}, nil
+		case 0xdc, 0xdd:
+			return d.array(n)
+		}
+		return d.object(n)
+	}
+
+	switch b {
+	case 0xc0:
+		return Zero{}, nil
+	case 0xc2:
+		return Bool{Value: false}, nil
+	case 0xc3:
+		return Bool{Value: true}, nil
+	case 0xca:
+		u, err := d.uint(4)
+
+		if err != nil {
+			return nil, err
+		}
+		return Float{Value: float64(math.Float32frombits(uint32(u)))}, nil
+	case 0xcb:
+		u, err := d.uint(8)
+
+		if err != nil {
+			return nil, err
+		}
+		return Float{Value: math.Float64frombits(u)}, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (b - 0xcc))
+
+		if err != nil {
+			return nil, err
+		}
+		return uintValue(u), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (b - 0xd0)
+
+		u, err := d.uint(n)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Sign extend the integer from its encoded size.
+		shift := uint(64 - n*8)
+		return Int{Value: int64(u<<shift) >> shift}, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.ext(1 << (b - 0xd4))
+	}
+	return nil, errors.New("invalid msgpack format byte 0x" + strconv.FormatUint(uint64(b), 16))
+}
+
+// DecodeMsgpack decodes the MessagePack value in the given reader. Maps are
+// decoded to objects with their keys in the order they appear, binary data to
+// a stream, and timestamps to a time.
+func DecodeMsgpack(r io.Reader) (Value, error) {
+	b, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d := &msgpackDecoder{
+		binReader: binReader{b: b},
+	}
+
+	val, err := d.decode()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if d.pos != len(b) {
+		return nil, errors.New("unexpected data after msgpack value")
+	}
+	return val, nil
+}
+
+// putUint writes the given unsigned integer in big-endian order using the
+// given number of bytes.
+func putUint(buf *bytes.Buffer, u uint64, n int) {
+	var b [8]byte
+
+	binary.BigEndian.PutUint64(b[:], u)
+	buf.Write(b[8-n:])
+}
+
+// msgpackHead writes the format byte and length for a str, bin, array, or map.
+// The fix format is used if the length is below fixmax.
+func msgpackHead(buf *bytes.Buffer, n int, fix byte, fixmax int, formats [3]byte) {
+	switch {
+	case n < fixmax:
+		buf.WriteByte(fix | byte(n))
+	case formats[0] != 0 && n <= math.MaxUint8:
+		buf.WriteByte(formats[0])
+		putUint(buf, uint64(n), 1)
+	case n <= math.MaxUint16:
+		buf.WriteByte(formats[1])
+		putUint(buf, uint64(n), 2)
+	default:
+		buf.WriteByte(formats[2])
+		putUint(buf, uint64(n), 4)
+	}
+}
+
+func encodeMsgpackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= math.MaxInt8:
+		buf.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		buf.WriteByte(byte(int8(i)))
+	case i > 0:
+		switch {
+		case i <= math.MaxUint8:
+			buf.WriteByte(0xcc)
+			putUint(buf, uint64(i), 1)
+		case i <= math.MaxUint16:
+			buf.WriteByte(0xcd)
+			putUint(buf, uint64(i), 2)
+		case i <= math.MaxUint32:
+			buf.WriteByte(0xce)
+			putUint(buf, uint64(i), 4)
+		default:
+			buf.WriteByte(0xcf)
+			putUint(buf, uint64(i), 8)
+		}
+	default:
+		switch {
+		case i >= math.MinInt8:
+			buf.WriteByte(0xd0)
+			putUint(buf, uint64(i), 1)
+		case i >= math.MinInt16:
+			buf.WriteByte(0xd1)
+			putUint(buf, uint64(i), 2)
+		case i >= math.MinInt32:
+			buf.WriteByte(0xd2)
+			putUint(buf, uint64(i), 4)
+		default:
+			buf.WriteByte(0xd3)
+			putUint(buf, uint64(i), 8)
+		}
+	}
+}
+
+func encodeMsgpackString(buf *bytes.Buffer, s string) {
+	msgpackHead(buf, len(s), 0xa0, 32, [3]byte{0xd9, 0xda, 0xdb})
+	buf.WriteString(s)
+}
+
+func encodeMsgpack(buf *bytes.Buffer, v Value) error {
+	switch v := v.(type) {
+	case *Object:
+		keys := v.keys()
+
+		msgpackHead(buf, len(keys), 0x80, 16, [3]byte{0, 0xde, 0xdf})
+
+		for _, k := range keys {
+			encodeMsgpackString(buf, k)
+
+			if err := encodeMsgpack(buf, v.Pairs[k]); err != nil {
+				return err
+			}
+		}
+	case *Array:
+		msgpackHead(buf, len(v.Items), 0x90, 16, [3]byte{0, 0xdc, 0xdd})
+
+		for _, it := range v.Items {
+			if err := encodeMsgpack(buf, it); err != nil {
+				return err
+			}
+		}
+	case String:
+		encodeMsgpackString(buf, v.Value)
+	case Int:
+		encodeMsgpackInt(buf, v.Value)
+	case Float:
+		if v.digits != "" {
+			if u, err := strconv.ParseUint(v.digits, 10, 64); err == nil {
+				buf.WriteByte(0xcf)
+				putUint(buf, u, 8)
+				return nil
+			}
+		}
+
+		buf.WriteByte(0xcb)
+		putUint(buf, math.Float64bits(v.Value), 8)
+	case Bool:
+		if v.Value {
+			buf.WriteByte(0xc3)
+			return nil
+		}
+		buf.WriteByte(0xc2)
+	case Time:
+		// Always use the 96-bit timestamp format, since it can hold any
+		// time.
+		buf.Write([]byte{0xc7, 12, 0xff})
+		putUint(buf, uint64(v.Value.Nanosecond()), 4)
+		putUint(buf, uint64(v.Value.Unix()), 8)
+	case Duration:
+		encodeMsgpackString(buf, v.Value.String())
+	case Stream:
+		b, err := readStream(v)
+
+		if err != nil {
+			return err
+		}
+
+		msgpackHead(buf, len(b), 0, 0, [3]byte{0xc4, 0xc5, 0xc6})
+		buf.Write(b)
+	case Zero:
+		buf.WriteByte(0xc0)
+	default:
+		return errors.New("cannot encode " + v.valueType().String() + " to msgpack")
+	}
+	return nil
+}
+
+// EncodeMsgpack encodes the given value to MessagePack. Streams are encoded as
+// binary data, and times as timestamps.
+func EncodeMsgpack(v Value) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := encodeMsgpack(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
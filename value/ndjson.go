@@ -0,0 +1,73 @@
+package value
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// newNDJSONDecoder returns a function that decodes the next JSON value from
+// the given reader each time it is called. Values are expected to be
+// separated by newlines, though any whitespace is accepted.
+func newNDJSONDecoder(r io.Reader) func() (Value, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	return func() (Value, error) {
+		return decodeJson(dec)
+	}
+}
+
+// DecodeNDJSON decodes all of the newline delimited JSON values in the given
+// reader into an array.
+func DecodeNDJSON(r io.Reader) (Value, error) {
+	next := newNDJSONDecoder(r)
+
+	items := make([]Value, 0)
+
+	for {
+		val, err := next()
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		items = append(items, val)
+	}
+	return CopyArray(items), nil
+}
+
+// IterateNDJSON returns an iterator that decodes each newline delimited JSON
+// value in the given stream as it is iterated over.
+func IterateNDJSON(s Stream) *Iterator {
+	return StreamIterator(s, newNDJSONDecoder(s))
+}
+
+// EncodeNDJSON encodes each item in the given array to JSON on its own line.
+// Any indentation in the given options is ignored.
+func EncodeNDJSON(v Value, opts JSONOptions) ([]byte, error) {
+	arr, err := ToArray(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Indent = ""
+
+	var buf bytes.Buffer
+
+	for _, it := range arr.Items {
+		b, err := EncodeJSON(it, opts)
+
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
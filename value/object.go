@@ -29,8 +29,67 @@ func ToObject(v Value) (*Object, error) {
 	return o, nil
 }
 
+// keys returns the keys of the object in order. Any keys that are not in the
+// order are sorted and returned after the ordered keys.
+func (o *Object) keys() []string {
+	if len(o.Order) == len(o.Pairs) {
+		return o.Order
+	}
+
+	keys := make([]string, 0, len(o.Pairs))
+	seen := make(map[string]struct{})
+
+	for _, k := range o.Order {
+		if _, ok := o.Pairs[k]; ok {
+			keys = append(keys, k)
+			seen[k] = struct{}{}
+		}
+	}
+
+	rest := make([]string, 0, len(o.Pairs)-len(keys))
+
+	for k := range o.Pairs {
+		if _, ok := seen[k]; !ok {
+			rest = append(rest, k)
+		}
+	}
+
+	sort.Strings(rest)
+
+	return append(keys, rest...)
+}
+
+// MarshalJSON encodes the object to JSON, with each key encoded in the order
+// in which it was defined.
 func (o *Object) MarshalJSON() ([]byte, error) {
-	return json.Marshal(o.Pairs)
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for i, k := range o.keys() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(k)
+
+		if err != nil {
+			return nil, err
+		}
+
+		val, err := json.Marshal(o.Pairs[k])
+
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
 }
 
 // Has checks to see if the current object has the current value, if that given
@@ -78,6 +137,7 @@ func (o *Object) Set(strict bool, key, val Value) error {
 	val0, ok := o.Pairs[str.Value]
 
 	if !ok {
+		o.Order = append(o.Order, str.Value)
 		o.Pairs[str.Value] = val
 		return nil
 	}
@@ -92,6 +152,58 @@ func (o *Object) Set(strict bool, key, val Value) error {
 	return nil
 }
 
+// Delete removes the value at the given key, if it exists.
+func (o *Object) Delete(key string) {
+	if _, ok := o.Pairs[key]; !ok {
+		return
+	}
+
+	delete(o.Pairs, key)
+
+	for i, k := range o.Order {
+		if k == key {
+			o.Order = append(o.Order[:i:i], o.Order[i+1:]...)
+			break
+		}
+	}
+}
+
+// Copy returns a shallow copy of the object.
+func (o *Object) Copy() *Object {
+	cp := &Object{
+		Order: make([]string, 0, len(o.Order)),
+		Pairs: make(map[string]Value, len(o.Pairs)),
+	}
+
+	for _, k := range o.Order {
+		cp.Order = append(cp.Order, k)
+		cp.Pairs[k] = o.Pairs[k]
+	}
+	return cp
+}
+
+// Keys returns an array of the keys in the object, in the order in which they
+// were defined.
+func (o *Object) Keys() *Array {
+	items := make([]Value, 0, len(o.Order))
+
+	for _, k := range o.Order {
+		items = append(items, String{Value: k})
+	}
+	return CopyArray(items)
+}
+
+// Values returns an array of the values in the object, in the order in which
+// they were defined. No type check is performed on the values.
+func (o *Object) Values() *Array {
+	items := make([]Value, 0, len(o.Order))
+
+	for _, k := range o.Order {
+		items = append(items, o.Pairs[k])
+	}
+	return CopyArray(items)
+}
+
 func (o *Object) Next() (Value, Value, error) {
 	if o.curr > len(o.Order)-1 {
 		// Reset the current for the next time the value is iterated over.
@@ -114,17 +226,16 @@ func (o *Object) String() string {
 
 	buf.WriteByte('(')
 
-	end := len(o.Pairs) - 1
-	i := 0
+	keys := o.keys()
+	end := len(keys) - 1
 
-	for k, v := range o.Pairs {
+	for i, k := range keys {
 		buf.WriteString(k + ":")
-		buf.WriteString(v.String())
+		buf.WriteString(o.Pairs[k].String())
 
 		if i != end {
 			buf.WriteByte(' ')
 		}
-		i++
 	}
 
 	buf.WriteByte(')')
@@ -0,0 +1,1255 @@
+package value
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// protoField is a field in a protobuf message. The typ is either the name of
+// a scalar type, or message or enum once the type name has been resolved.
+type protoField struct {
+	name     string
+	number   int
+	typ      string
+	typeName string
+	repeated bool
+	packed   bool
+}
+
+type protoMessage struct {
+	name     string
+	fields   []*protoField
+	byName   map[string]*protoField
+	byNum    map[int]*protoField
+	mapEntry bool
+}
+
+type protoEnum struct {
+	name   string
+	byName map[string]int32
+	byNum  map[int32]string
+}
+
+type protoMethod struct {
+	input  string
+	output string
+	stream bool
+}
+
+// ProtoSchema holds the messages, enums, and services loaded from either a set
+// of .proto files, or a descriptor set.
+type ProtoSchema struct {
+	messages map[string]*protoMessage
+	enums    map[string]*protoEnum
+	methods  map[string]*protoMethod
+
+	// scopes records the scope each unresolved type name was declared in.
+	scopes map[*protoField]string
+	files  map[string]struct{}
+}
+
+// protoScalars is the set of scalar types that can be given to a field.
+var protoScalars = map[string]struct{}{
+	"double":   {},
+	"float":    {},
+	"int32":    {},
+	"int64":    {},
+	"uint32":   {},
+	"uint64":   {},
+	"sint32":   {},
+	"sint64":   {},
+	"fixed32":  {},
+	"fixed64":  {},
+	"sfixed32": {},
+	"sfixed64": {},
+	"bool":     {},
+	"string":   {},
+	"bytes":    {},
+}
+
+// protoWellKnown holds the source of the well-known types that can be
+// imported by a .proto file without being on disk.
+var protoWellKnown = map[string]string{
+	"google/protobuf/timestamp.proto": `syntax = "proto3";
+package google.protobuf;
+message Timestamp { int64 seconds = 1; int32 nanos = 2; }`,
+	"google/protobuf/duration.proto": `syntax = "proto3";
+package google.protobuf;
+message Duration { int64 seconds = 1; int32 nanos = 2; }`,
+	"google/protobuf/empty.proto": `syntax = "proto3";
+package google.protobuf;
+message Empty {}`,
+}
+
+func newProtoSchema() *ProtoSchema {
+	return &ProtoSchema{
+		messages: make(map[string]*protoMessage),
+		enums:    make(map[string]*protoEnum),
+		methods:  make(map[string]*protoMethod),
+		scopes:   make(map[*protoField]string),
+		files:    make(map[string]struct{}),
+	}
+}
+
+// LoadProto loads the schema from the given file. If the file has the .proto
+// extension, then it is parsed as a protobuf definition, along with any files
+// it imports, otherwise it is read as a binary FileDescriptorSet. Imports are
+// resolved relative to the directory of the file.
+func LoadProto(name string) (*ProtoSchema, error) {
+	s := newProtoSchema()
+
+	if filepath.Ext(name) == ".proto" {
+		if err := s.parseFile(filepath.Dir(name), filepath.Base(name)); err != nil {
+			return nil, err
+		}
+	} else {
+		b, err := os.ReadFile(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.loadDescriptorSet(b); err != nil {
+			return nil, errors.New(name + ": " + err.Error())
+		}
+	}
+
+	if err := s.resolve(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Method returns the names of the input, and output messages for the given
+// method. The method is in the form package.Service/Method.
+func (s *ProtoSchema) Method(name string) (string, string, error) {
+	m, ok := s.methods[strings.TrimPrefix(name, "/")]
+
+	if !ok {
+		return "", "", errors.New("unknown method " + name)
+	}
+
+	if m.stream {
+		return "", "", errors.New("cannot call streaming method " + name)
+	}
+	return m.input, m.output, nil
+}
+
+func (s *ProtoSchema) message(name string) (*protoMessage, error) {
+	msg, ok := s.messages[strings.TrimPrefix(name, ".")]
+
+	if !ok {
+		return nil, errors.New("unknown message " + name)
+	}
+	return msg, nil
+}
+
+func (s *ProtoSchema) addMessage(msg *protoMessage) {
+	msg.byName = make(map[string]*protoField)
+	msg.byNum = make(map[int]*protoField)
+
+	for _, f := range msg.fields {
+		msg.byName[f.name] = f
+		msg.byNum[f.number] = f
+	}
+	s.messages[msg.name] = msg
+}
+
+// resolve resolves the type names of all message fields, and methods. Names
+// are resolved following the scoping rules of protobuf, where the innermost
+// scope is searched first.
+func (s *ProtoSchema) resolve() error {
+	lookup := func(scope, name string) (string, bool) {
+		if strings.HasPrefix(name, ".") {
+			name = name[1:]
+
+			_, msg := s.messages[name]
+			_, enum := s.enums[name]
+
+			return name, msg || enum
+		}
+
+		for {
+			full := name
+
+			if scope != "" {
+				full = scope + "." + name
+			}
+
+			if _, ok := s.messages[full]; ok {
+				return full, true
+			}
+
+			if _, ok := s.enums[full]; ok {
+				return full, true
+			}
+
+			if scope == "" {
+				return "", false
+			}
+
+			if i := strings.LastIndexByte(scope, '.'); i >= 0 {
+				scope = scope[:i]
+			} else {
+				scope = ""
+			}
+		}
+	}
+
+	for _, msg := range s.messages {
+		for _, f := range msg.fields {
+			if _, ok := protoScalars[f.typ]; ok || f.typ == "message" || f.typ == "enum" {
+				continue
+			}
+
+			full, ok := lookup(s.scopes[f], f.typeName)
+
+			if !ok {
+				return errors.New("unknown type " + f.typeName + " for field " + msg.name + "." + f.name)
+			}
+
+			f.typeName = full
+			f.typ = "message"
+
+			if _, ok := s.enums[full]; ok {
+				f.typ = "enum"
+				f.packed = f.packed && f.repeated
+			} else {
+				f.packed = false
+			}
+		}
+	}
+
+	for name, m := range s.methods {
+		for _, p := range []*string{&m.input, &m.output} {
+			scope := name[:strings.IndexByte(name, '/')]
+
+			if i := strings.LastIndexByte(scope, '.'); i >= 0 {
+				scope = scope[:i]
+			} else {
+				scope = ""
+			}
+
+			full, ok := lookup(scope, *p)
+
+			if !ok {
+				return errors.New("unknown type " + *p + " for method " + name)
+			}
+			*p = full
+		}
+	}
+	return nil
+}
+
+type protoToken struct {
+	text string
+	line int
+}
+
+// tokenize splits the given .proto source into tokens. String literals are
+// kept quoted so they can be told apart from identifiers.
+func tokenizeProto(src string) ([]protoToken, error) {
+	toks := make([]protoToken, 0)
+	line := 1
+
+	for i := 0; i < len(src); {
+		c := src[i]
+
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+
+			if end < 0 {
+				return nil, errors.New("line " + strconv.Itoa(line) + ": unterminated comment")
+			}
+
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+
+			if j >= len(src) {
+				return nil, errors.New("line " + strconv.Itoa(line) + ": unterminated string")
+			}
+
+			toks = append(toks, protoToken{text: src[i : j+1], line: line})
+			i = j + 1
+		case c == '_' || c == '.' || c == '-' || c == '+' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+
+			for j < len(src) {
+				b := src[j]
+
+				if b != '_' && b != '.' && !(b >= '0' && b <= '9') && !(b >= 'a' && b <= 'z') && !(b >= 'A' && b <= 'Z') {
+					break
+				}
+				j++
+			}
+
+			toks = append(toks, protoToken{text: src[i:j], line: line})
+			i = j
+		default:
+			toks = append(toks, protoToken{text: string(c), line: line})
+			i++
+		}
+	}
+	return toks, nil
+}
+
+type protoParser struct {
+	schema *ProtoSchema
+	fname  string
+	toks   []protoToken
+	pos    int
+	pkg    string
+	proto3 bool
+}
+
+func (p *protoParser) err(msg string) error {
+	line := 0
+
+	if p.pos < len(p.toks) {
+		line = p.toks[p.pos].line
+	} else if len(p.toks) > 0 {
+		line = p.toks[len(p.toks)-1].line
+	}
+	return errors.New(p.fname + ":" + strconv.Itoa(line) + ": " + msg)
+}
+
+func (p *protoParser) peek() string {
+	if p.pos >= len(p.toks) {
+		return ""
+	}
+	return p.toks[p.pos].text
+}
+
+func (p *protoParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *protoParser) got(tok string) bool {
+	if p.peek() == tok {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *protoParser) want(tok string) error {
+	if !p.got(tok) {
+		if p.peek() == "" {
+			return p.err("expected " + tok + ", found end of file")
+		}
+		return p.err("expected " + tok + ", found " + p.peek())
+	}
+	return nil
+}
+
+func (p *protoParser) str() (string, error) {
+	tok := p.next()
+
+	if tok == "" || (tok[0] != '"' && tok[0] != '\'') {
+		p.pos--
+		return "", p.err("expected string")
+	}
+
+	if tok[0] == '\'' {
+		tok = `"` + strings.ReplaceAll(tok[1:len(tok)-1], `"`, `\"`) + `"`
+	}
+
+	s, err := strconv.Unquote(tok)
+
+	if err != nil {
+		return "", p.err("invalid string " + tok)
+	}
+	return s, nil
+}
+
+func (p *protoParser) int() (int, error) {
+	tok := p.next()
+
+	i, err := strconv.ParseInt(tok, 0, 64)
+
+	if err != nil {
+		p.pos--
+		return 0, p.err("expected integer, found " + tok)
+	}
+	return int(i), nil
+}
+
+// skip skips over the tokens up to, and including the next ; at the current
+// level of nesting, or the end of a block if one is found first.
+func (p *protoParser) skip() error {
+	depth := 0
+
+	for {
+		switch p.next() {
+		case "":
+			return p.err("unexpected end of file")
+		case "{", "[", "(":
+			depth++
+		case "}", "]", ")":
+			depth--
+
+			if depth == 0 && p.toks[p.pos-1].text == "}" {
+				p.got(";")
+				return nil
+			}
+		case ";":
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// fieldOpts parses the options of a field, returning whether the field has
+// packed set.
+func (p *protoParser) fieldOpts(packed bool) (bool, error) {
+	if !p.got("[") {
+		return packed, nil
+	}
+
+	for {
+		name := p.next()
+
+		if err := p.want("="); err != nil {
+			return packed, err
+		}
+
+		val := p.next()
+
+		if name == "packed" {
+			packed = val == "true"
+		}
+
+		if val == "{" {
+			p.pos--
+
+			if err := p.skipBlock(); err != nil {
+				return packed, err
+			}
+		}
+
+		if p.got("]") {
+			return packed, nil
+		}
+
+		if err := p.want(","); err != nil {
+			return packed, err
+		}
+	}
+}
+
+func (p *protoParser) skipBlock() error {
+	if err := p.want("{"); err != nil {
+		return err
+	}
+
+	for depth := 1; depth > 0; {
+		switch p.next() {
+		case "":
+			return p.err("unexpected end of file")
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+	}
+	return nil
+}
+
+func (p *protoParser) enum(scope string) error {
+	name := p.next()
+
+	enum := &protoEnum{
+		name:   scope + name,
+		byName: make(map[string]int32),
+		byNum:  make(map[int32]string),
+	}
+
+	if err := p.want("{"); err != nil {
+		return err
+	}
+
+	for !p.got("}") {
+		switch tok := p.peek(); tok {
+		case "":
+			return p.err("unexpected end of file")
+		case ";":
+			p.pos++
+		case "option", "reserved":
+			if err := p.skip(); err != nil {
+				return err
+			}
+		default:
+			p.pos++
+
+			if err := p.want("="); err != nil {
+				return err
+			}
+
+			num, err := p.int()
+
+			if err != nil {
+				return err
+			}
+
+			if _, err := p.fieldOpts(false); err != nil {
+				return err
+			}
+
+			if err := p.want(";"); err != nil {
+				return err
+			}
+
+			enum.byName[tok] = int32(num)
+
+			if _, ok := enum.byNum[int32(num)]; !ok {
+				enum.byNum[int32(num)] = tok
+			}
+		}
+	}
+
+	p.schema.enums[enum.name] = enum
+	return nil
+}
+
+// field parses a field of the given type in the given message.
+func (p *protoParser) field(msg *protoMessage, typ string, repeated bool) error {
+	name := p.next()
+
+	if err := p.want("="); err != nil {
+		return err
+	}
+
+	num, err := p.int()
+
+	if err != nil {
+		return err
+	}
+
+	_, scalar := protoScalars[typ]
+
+	packed, err := p.fieldOpts(p.proto3 && repeated && scalar && typ != "string" && typ != "bytes")
+
+	if err != nil {
+		return err
+	}
+
+	f := &protoField{
+		name:     name,
+		number:   num,
+		typ:      typ,
+		repeated: repeated,
+		packed:   packed,
+	}
+
+	if !scalar {
+		f.typeName = typ
+		p.schema.scopes[f] = msg.name
+	}
+
+	msg.fields = append(msg.fields, f)
+	return p.want(";")
+}
+
+// mapField parses a map field, which is represented as a repeated field of a
+// nested entry message with a key and value.
+func (p *protoParser) mapField(msg *protoMessage) error {
+	if err := p.want("<"); err != nil {
+		return err
+	}
+
+	key := p.next()
+
+	if err := p.want(","); err != nil {
+		return err
+	}
+
+	val := p.next()
+
+	if err := p.want(">"); err != nil {
+		return err
+	}
+
+	name := p.peek()
+
+	entry := &protoMessage{
+		name:     msg.name + "." + protoEntryName(name),
+		mapEntry: true,
+	}
+
+	if err := p.field(msg, entry.name, true); err != nil {
+		return err
+	}
+
+	f := msg.fields[len(msg.fields)-1]
+	f.typeName = "." + entry.name
+
+	entry.fields = []*protoField{
+		{name: "key", number: 1, typ: key},
+		{name: "value", number: 2, typ: val},
+	}
+
+	if _, ok := protoScalars[val]; !ok {
+		entry.fields[1].typeName = val
+		p.schema.scopes[entry.fields[1]] = msg.name
+	}
+
+	p.schema.addMessage(entry)
+	return nil
+}
+
+// protoEntryName returns the name of the entry message for the given map field
+// name, for example tag_counts becomes TagCountsEntry.
+func protoEntryName(name string) string {
+	var buf strings.Builder
+
+	upper := true
+
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+
+		if upper {
+			buf.WriteString(strings.ToUpper(string(r)))
+			upper = false
+			continue
+		}
+		buf.WriteRune(r)
+	}
+
+	buf.WriteString("Entry")
+	return buf.String()
+}
+
+func (p *protoParser) message(scope string) error {
+	msg := &protoMessage{
+		name: scope + p.next(),
+	}
+
+	if err := p.want("{"); err != nil {
+		return err
+	}
+
+	if err := p.body(msg, "}"); err != nil {
+		return err
+	}
+
+	p.schema.addMessage(msg)
+	return nil
+}
+
+// body parses the body of a message, or oneof up until the given end token.
+func (p *protoParser) body(msg *protoMessage, end string) error {
+	for !p.got(end) {
+		switch tok := p.next(); tok {
+		case "":
+			return p.err("unexpected end of file")
+		case ";":
+		case "message":
+			if err := p.message(msg.name + "."); err != nil {
+				return err
+			}
+		case "enum":
+			if err := p.enum(msg.name + "."); err != nil {
+				return err
+			}
+		case "oneof":
+			p.next()
+
+			if err := p.want("{"); err != nil {
+				return err
+			}
+
+			if err := p.body(msg, "}"); err != nil {
+				return err
+			}
+		case "map":
+			if err := p.mapField(msg); err != nil {
+				return err
+			}
+		case "option", "reserved", "extensions":
+			p.pos--
+
+			if err := p.skip(); err != nil {
+				return err
+			}
+		case "extend":
+			p.next()
+
+			if err := p.skipBlock(); err != nil {
+				return err
+			}
+		case "group":
+			return p.err("groups are not supported")
+		case "repeated":
+			if err := p.field(msg, p.next(), true); err != nil {
+				return err
+			}
+		case "optional", "required":
+			if err := p.field(msg, p.next(), false); err != nil {
+				return err
+			}
+		default:
+			if err := p.field(msg, tok, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *protoParser) service() error {
+	svc := p.next()
+
+	if p.pkg != "" {
+		svc = p.pkg + "." + svc
+	}
+
+	if err := p.want("{"); err != nil {
+		return err
+	}
+
+	for !p.got("}") {
+		switch tok := p.next(); tok {
+		case "":
+			return p.err("unexpected end of file")
+		case ";":
+		case "option":
+			p.pos--
+
+			if err := p.skip(); err != nil {
+				return err
+			}
+		case "rpc":
+			name := p.next()
+
+			m := &protoMethod{}
+
+			for i, typ := range []*string{&m.input, &m.output} {
+				if i > 0 {
+					if err := p.want("returns"); err != nil {
+						return err
+					}
+				}
+
+				if err := p.want("("); err != nil {
+					return err
+				}
+
+				if p.got("stream") {
+					m.stream = true
+				}
+
+				*typ = p.next()
+
+				if err := p.want(")"); err != nil {
+					return err
+				}
+			}
+
+			if p.peek() == "{" {
+				if err := p.skipBlock(); err != nil {
+					return err
+				}
+				p.got(";")
+			} else if err := p.want(";"); err != nil {
+				return err
+			}
+
+			p.schema.methods[svc+"/"+name] = m
+		default:
+			return p.err("unexpected " + tok + " in service")
+		}
+	}
+	return nil
+}
+
+// parseFile parses the .proto file with the given name in the given
+// directory, along with any of the files it imports.
+func (s *ProtoSchema) parseFile(dir, name string) error {
+	if _, ok := s.files[name]; ok {
+		return nil
+	}
+
+	s.files[name] = struct{}{}
+
+	b, err := os.ReadFile(filepath.Join(dir, name))
+
+	if err != nil {
+		src, ok := protoWellKnown[name]
+
+		if !ok {
+			return err
+		}
+		b = []byte(src)
+	}
+
+	toks, err := tokenizeProto(string(b))
+
+	if err != nil {
+		return errors.New(name + ": " + err.Error())
+	}
+
+	p := &protoParser{
+		schema: s,
+		fname:  name,
+		toks:   toks,
+	}
+
+	for p.pos < len(p.toks) {
+		switch tok := p.next(); tok {
+		case ";":
+		case "syntax", "edition":
+			if err := p.want("="); err != nil {
+				return err
+			}
+
+			syntax, err := p.str()
+
+			if err != nil {
+				return err
+			}
+
+			p.proto3 = syntax != "proto2"
+
+			if err := p.want(";"); err != nil {
+				return err
+			}
+		case "package":
+			p.pkg = p.next()
+
+			if err := p.want(";"); err != nil {
+				return err
+			}
+		case "import":
+			if p.peek() == "public" || p.peek() == "weak" {
+				p.pos++
+			}
+
+			path, err := p.str()
+
+			if err != nil {
+				return err
+			}
+
+			if err := p.want(";"); err != nil {
+				return err
+			}
+
+			if err := s.parseFile(dir, path); err != nil {
+				return err
+			}
+		case "option":
+			p.pos--
+
+			if err := p.skip(); err != nil {
+				return err
+			}
+		case "message":
+			if err := p.message(p.scope()); err != nil {
+				return err
+			}
+		case "enum":
+			if err := p.enum(p.scope()); err != nil {
+				return err
+			}
+		case "service":
+			if err := p.service(); err != nil {
+				return err
+			}
+		case "extend":
+			p.next()
+
+			if err := p.skipBlock(); err != nil {
+				return err
+			}
+		default:
+			p.pos--
+			return p.err("unexpected " + tok)
+		}
+	}
+	return nil
+}
+
+// scope returns the scope for top-level declarations in the file.
+func (p *protoParser) scope() string {
+	if p.pkg == "" {
+		return ""
+	}
+	return p.pkg + "."
+}
+
+// protoDescTypes maps the types in a FieldDescriptorProto to their names.
+var protoDescTypes = map[uint64]string{
+	1:  "double",
+	2:  "float",
+	3:  "int64",
+	4:  "uint64",
+	5:  "int32",
+	6:  "fixed64",
+	7:  "fixed32",
+	8:  "bool",
+	9:  "string",
+	11: "message",
+	12: "bytes",
+	13: "uint32",
+	14: "enum",
+	15: "sfixed32",
+	16: "sfixed64",
+	17: "sint32",
+	18: "sint64",
+}
+
+// protoRaw is a decoded field from a message without a schema. The value is
+// either the bytes of a length delimited field, or the number of a varint or
+// fixed field.
+type protoRaw struct {
+	num uint64
+	b   []byte
+	n   uint64
+}
+
+// rawMessage decodes the fields of the given message without a schema.
+func rawMessage(b []byte) ([]protoRaw, error) {
+	r := &binReader{b: b}
+
+	fields := make([]protoRaw, 0)
+
+	for r.pos < len(r.b) {
+		tag, err := r.varint()
+
+		if err != nil {
+			return nil, err
+		}
+
+		f := protoRaw{num: tag >> 3}
+
+		switch tag & 7 {
+		case 0:
+			f.n, err = r.varint()
+		case 1:
+			f.n, err = r.uintLE(8)
+		case 2:
+			var n uint64
+
+			if n, err = r.varint(); err == nil {
+				if n > uint64(len(r.b)) {
+					return nil, errors.New("invalid protobuf field length")
+				}
+				f.b, err = r.next(int(n))
+			}
+		case 5:
+			f.n, err = r.uintLE(4)
+		default:
+			return nil, errors.New("unsupported protobuf wire type " + strconv.FormatUint(tag&7, 10))
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// loadDescriptorSet loads the messages, enums, and services from the given
+// FileDescriptorSet.
+func (s *ProtoSchema) loadDescriptorSet(b []byte) error {
+	files, err := rawMessage(b)
+
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.num != 1 {
+			continue
+		}
+
+		fields, err := rawMessage(file.b)
+
+		if err != nil {
+			return err
+		}
+
+		var (
+			pkg      string
+			proto3   bool
+			msgs     [][]byte
+			enums    [][]byte
+			services [][]byte
+		)
+
+		for _, f := range fields {
+			switch f.num {
+			case 2:
+				pkg = string(f.b)
+			case 4:
+				msgs = append(msgs, f.b)
+			case 5:
+				enums = append(enums, f.b)
+			case 6:
+				services = append(services, f.b)
+			case 12:
+				proto3 = string(f.b) == "proto3"
+			}
+		}
+
+		scope := ""
+
+		if pkg != "" {
+			scope = pkg + "."
+		}
+
+		for _, b := range msgs {
+			if err := s.descMessage(scope, proto3, b); err != nil {
+				return err
+			}
+		}
+
+		for _, b := range enums {
+			if err := s.descEnum(scope, b); err != nil {
+				return err
+			}
+		}
+
+		for _, b := range services {
+			if err := s.descService(pkg, b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ProtoSchema) descEnum(scope string, b []byte) error {
+	fields, err := rawMessage(b)
+
+	if err != nil {
+		return err
+	}
+
+	enum := &protoEnum{
+		byName: make(map[string]int32),
+		byNum:  make(map[int32]string),
+	}
+
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			enum.name = scope + string(f.b)
+		case 2:
+			vals, err := rawMessage(f.b)
+
+			if err != nil {
+				return err
+			}
+
+			var (
+				name string
+				num  int32
+			)
+
+			for _, v := range vals {
+				switch v.num {
+				case 1:
+					name = string(v.b)
+				case 2:
+					num = int32(v.n)
+				}
+			}
+
+			enum.byName[name] = num
+
+			if _, ok := enum.byNum[num]; !ok {
+				enum.byNum[num] = name
+			}
+		}
+	}
+
+	s.enums[enum.name] = enum
+	return nil
+}
+
+func (s *ProtoSchema) descMessage(scope string, proto3 bool, b []byte) error {
+	fields, err := rawMessage(b)
+
+	if err != nil {
+		return err
+	}
+
+	msg := &protoMessage{}
+
+	// The name is needed as the scope of any nested types, so find it first.
+	for _, f := range fields {
+		if f.num == 1 {
+			msg.name = scope + string(f.b)
+		}
+	}
+
+	for _, f := range fields {
+		switch f.num {
+		case 2:
+			field, err := descField(proto3, f.b)
+
+			if err != nil {
+				return err
+			}
+			msg.fields = append(msg.fields, field)
+		case 3:
+			if err := s.descMessage(msg.name+".", proto3, f.b); err != nil {
+				return err
+			}
+		case 4:
+			if err := s.descEnum(msg.name+".", f.b); err != nil {
+				return err
+			}
+		case 7:
+			opts, err := rawMessage(f.b)
+
+			if err != nil {
+				return err
+			}
+
+			for _, opt := range opts {
+				if opt.num == 7 {
+					msg.mapEntry = opt.n != 0
+				}
+			}
+		}
+	}
+
+	s.addMessage(msg)
+	return nil
+}
+
+func descField(proto3 bool, b []byte) (*protoField, error) {
+	fields, err := rawMessage(b)
+
+	if err != nil {
+		return nil, err
+	}
+
+	f := &protoField{}
+
+	packed := proto3
+
+	for _, fld := range fields {
+		switch fld.num {
+		case 1:
+			f.name = string(fld.b)
+		case 3:
+			f.number = int(fld.n)
+		case 4:
+			f.repeated = fld.n == 3
+		case 5:
+			typ, ok := protoDescTypes[fld.n]
+
+			if !ok {
+				return nil, errors.New("unsupported field type " + strconv.FormatUint(fld.n, 10))
+			}
+			f.typ = typ
+		case 6:
+			f.typeName = string(fld.b)
+		case 8:
+			opts, err := rawMessage(fld.b)
+
+			if err != nil {
+				return nil, err
+			}
+
+			for _, opt := range opts {
+				if opt.num == 2 {
+					packed = opt.n != 0
+				}
+			}
+		}
+	}
+
+	_, scalar := protoScalars[f.typ]
+
+	f.packed = packed && f.repeated && (f.typ == "enum" || scalar && f.typ != "string" && f.typ != "bytes")
+
+	// Leave the type unresolved so the type name is looked up, and checked.
+	if f.typ == "message" || f.typ == "enum" {
+		f.typ = f.typeName
+	}
+	return f, nil
+}
+
+func (s *ProtoSchema) descService(pkg string, b []byte) error {
+	fields, err := rawMessage(b)
+
+	if err != nil {
+		return err
+	}
+
+	var svc string
+
+	for _, f := range fields {
+		if f.num == 1 {
+			svc = string(f.b)
+		}
+	}
+
+	if pkg != "" {
+		svc = pkg + "." + svc
+	}
+
+	for _, f := range fields {
+		if f.num != 2 {
+			continue
+		}
+
+		vals, err := rawMessage(f.b)
+
+		if err != nil {
+			return err
+		}
+
+		var name string
+
+		m := &protoMethod{}
+
+		for _, v := range vals {
+			switch v.num {
+			case 1:
+				name = string(v.b)
+			case 2:
+				m.input = string(v.b)
+			case 3:
+				m.output = string(v.b)
+			case 5, 6:
+				m.stream = m.stream || v.n != 0
+			}
+		}
+		s.methods[svc+"/"+name] = m
+	}
+	return nil
+}
@@ -0,0 +1,760 @@
+package value
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// Protobuf wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// varint reads a base 128 varint.
+func (r *binReader) varint() (uint64, error) {
+	var u uint64
+
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, err := r.byte()
+
+		if err != nil {
+			return 0, err
+		}
+
+		u |= uint64(b&0x7f) << shift
+
+		if b < 0x80 {
+			return u, nil
+		}
+	}
+	return 0, errors.New("varint overflows 64 bits")
+}
+
+// uintLE reads a little-endian unsigned integer of either 4 or 8 bytes.
+func (r *binReader) uintLE(n int) (uint64, error) {
+	b, err := r.next(n)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if n == 4 {
+		return uint64(binary.LittleEndian.Uint32(b)), nil
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+// protoWireType returns the wire type used to encode the given field type.
+func protoWireType(typ string) uint64 {
+	switch typ {
+	case "double", "fixed64", "sfixed64":
+		return protoFixed64
+	case "float", "fixed32", "sfixed32":
+		return protoFixed32
+	case "string", "bytes", "message":
+		return protoBytes
+	}
+	return protoVarint
+}
+
+func putVarint(buf *bytes.Buffer, u uint64) {
+	var b [binary.MaxVarintLen64]byte
+
+	n := binary.PutUvarint(b[:], u)
+	buf.Write(b[:n])
+}
+
+func putProtoTag(buf *bytes.Buffer, num int, wt uint64) {
+	putVarint(buf, uint64(num)<<3|wt)
+}
+
+func putProtoBytes(buf *bytes.Buffer, b []byte) {
+	putVarint(buf, uint64(len(b)))
+	buf.Write(b)
+}
+
+// protoInt returns the integer for the given value, checking that it is in the
+// range of the given bit size.
+func protoInt(v Value, bits int) (int64, error) {
+	i, ok := v.(Int)
+
+	if !ok {
+		return 0, errors.New("expected int, got " + v.valueType().String())
+	}
+
+	if bits == 32 && (i.Value < math.MinInt32 || i.Value > math.MaxInt32) {
+		return 0, errors.New("int " + strconv.FormatInt(i.Value, 10) + " overflows int32")
+	}
+	return i.Value, nil
+}
+
+// protoUint returns the unsigned integer for the given value. Floats that keep
+// the digits of an unsigned integer too large for an Int are accepted.
+func protoUint(v Value, bits int) (uint64, error) {
+	if f, ok := v.(Float); ok && f.digits != "" {
+		if i, ok := new(big.Int).SetString(f.digits, 10); ok && i.IsUint64() && bits == 64 {
+			return i.Uint64(), nil
+		}
+	}
+
+	i, ok := v.(Int)
+
+	if !ok {
+		return 0, errors.New("expected int, got " + v.valueType().String())
+	}
+
+	if i.Value < 0 || bits == 32 && i.Value > math.MaxUint32 {
+		return 0, errors.New("int " + strconv.FormatInt(i.Value, 10) + " overflows uint" + strconv.Itoa(bits))
+	}
+	return uint64(i.Value), nil
+}
+
+func protoFloat(v Value) (float64, error) {
+	switch v := v.(type) {
+	case Int:
+		return float64(v.Value), nil
+	case Float:
+		return v.Value, nil
+	}
+	return 0, errors.New("expected float, got " + v.valueType().String())
+}
+
+// encodeScalar encodes the given value for the field, without the tag.
+func (s *ProtoSchema) encodeScalar(buf *bytes.Buffer, f *protoField, v Value) error {
+	switch f.typ {
+	case "int32", "int64":
+		bits := 64
+
+		if f.typ == "int32" {
+			bits = 32
+		}
+
+		i, err := protoInt(v, bits)
+
+		if err != nil {
+			return err
+		}
+		putVarint(buf, uint64(i))
+	case "sint32", "sint64":
+		bits := 64
+
+		if f.typ == "sint32" {
+			bits = 32
+		}
+
+		i, err := protoInt(v, bits)
+
+		if err != nil {
+			return err
+		}
+		putVarint(buf, uint64(i<<1)^uint64(i>>63))
+	case "uint32", "uint64":
+		bits := 64
+
+		if f.typ == "uint32" {
+			bits = 32
+		}
+
+		u, err := protoUint(v, bits)
+
+		if err != nil {
+			return err
+		}
+		putVarint(buf, u)
+	case "fixed32":
+		u, err := protoUint(v, 32)
+
+		if err != nil {
+			return err
+		}
+		putUintLE(buf, u, 4)
+	case "fixed64":
+		u, err := protoUint(v, 64)
+
+		if err != nil {
+			return err
+		}
+		putUintLE(buf, u, 8)
+	case "sfixed32":
+		i, err := protoInt(v, 32)
+
+		if err != nil {
+			return err
+		}
+		putUintLE(buf, uint64(uint32(i)), 4)
+	case "sfixed64":
+		i, err := protoInt(v, 64)
+
+		if err != nil {
+			return err
+		}
+		putUintLE(buf, uint64(i), 8)
+	case "float":
+		fl, err := protoFloat(v)
+
+		if err != nil {
+			return err
+		}
+		putUintLE(buf, uint64(math.Float32bits(float32(fl))), 4)
+	case "double":
+		fl, err := protoFloat(v)
+
+		if err != nil {
+			return err
+		}
+		putUintLE(buf, math.Float64bits(fl), 8)
+	case "bool":
+		b, ok := v.(Bool)
+
+		if !ok {
+			return errors.New("expected bool, got " + v.valueType().String())
+		}
+
+		if b.Value {
+			buf.WriteByte(1)
+			break
+		}
+		buf.WriteByte(0)
+	case "enum":
+		enum := s.enums[f.typeName]
+
+		switch v := v.(type) {
+		case String:
+			num, ok := enum.byName[v.Value]
+
+			if !ok {
+				return errors.New("unknown value " + v.Value + " for enum " + enum.name)
+			}
+			putVarint(buf, uint64(int64(num)))
+		case Int:
+			if v.Value < math.MinInt32 || v.Value > math.MaxInt32 {
+				return errors.New("int " + strconv.FormatInt(v.Value, 10) + " overflows enum " + enum.name)
+			}
+			putVarint(buf, uint64(v.Value))
+		default:
+			return errors.New("expected string or int for enum " + enum.name + ", got " + v.valueType().String())
+		}
+	case "string":
+		str, ok := v.(String)
+
+		if !ok {
+			return errors.New("expected string, got " + v.valueType().String())
+		}
+		putProtoBytes(buf, []byte(str.Value))
+	case "bytes":
+		switch v := v.(type) {
+		case String:
+			putProtoBytes(buf, []byte(v.Value))
+		case Stream:
+			b, err := readStream(v)
+
+			if err != nil {
+				return err
+			}
+			putProtoBytes(buf, b)
+		default:
+			return errors.New("expected string or stream, got " + v.valueType().String())
+		}
+	case "message":
+		b, err := s.EncodeMessage(f.typeName, v)
+
+		if err != nil {
+			return err
+		}
+		putProtoBytes(buf, b)
+	}
+	return nil
+}
+
+func putUintLE(buf *bytes.Buffer, u uint64, n int) {
+	var b [8]byte
+
+	binary.LittleEndian.PutUint64(b[:], u)
+	buf.Write(b[:n])
+}
+
+// encodeField encodes the given value for the field along with its tag.
+// Repeated fields are encoded from an array, and map fields from an object.
+func (s *ProtoSchema) encodeField(buf *bytes.Buffer, f *protoField, v Value) error {
+	if !f.repeated {
+		putProtoTag(buf, f.number, protoWireType(f.typ))
+		return s.encodeScalar(buf, f, v)
+	}
+
+	if f.typ == "message" && s.messages[f.typeName].mapEntry {
+		obj, ok := v.(*Object)
+
+		if !ok {
+			return errors.New("expected object, got " + v.valueType().String())
+		}
+
+		entry := s.messages[f.typeName]
+
+		key, val := entry.byNum[1], entry.byNum[2]
+
+		for _, k := range obj.keys() {
+			kv, err := protoMapKey(key, k)
+
+			if err != nil {
+				return err
+			}
+
+			var b bytes.Buffer
+
+			if err := s.encodeField(&b, key, kv); err != nil {
+				return err
+			}
+
+			if err := s.encodeField(&b, val, obj.Pairs[k]); err != nil {
+				return errors.New("value error " + k + ": " + err.Error())
+			}
+
+			putProtoTag(buf, f.number, protoBytes)
+			putProtoBytes(buf, b.Bytes())
+		}
+		return nil
+	}
+
+	arr, ok := v.(*Array)
+
+	if !ok {
+		return errors.New("expected array, got " + v.valueType().String())
+	}
+
+	if f.packed {
+		var b bytes.Buffer
+
+		for _, it := range arr.Items {
+			if err := s.encodeScalar(&b, f, it); err != nil {
+				return err
+			}
+		}
+
+		putProtoTag(buf, f.number, protoBytes)
+		putProtoBytes(buf, b.Bytes())
+		return nil
+	}
+
+	for _, it := range arr.Items {
+		putProtoTag(buf, f.number, protoWireType(f.typ))
+
+		if err := s.encodeScalar(buf, f, it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// protoMapKey returns the value for the given object key, converted to the
+// type of the map key.
+func protoMapKey(f *protoField, k string) (Value, error) {
+	switch f.typ {
+	case "string":
+		return String{Value: k}, nil
+	case "bool":
+		b, err := strconv.ParseBool(k)
+
+		if err != nil {
+			return nil, errors.New("invalid bool map key " + k)
+		}
+		return Bool{Value: b}, nil
+	}
+
+	if u, err := strconv.ParseUint(k, 10, 64); err == nil {
+		return uintValue(u), nil
+	}
+
+	i, err := strconv.ParseInt(k, 10, 64)
+
+	if err != nil {
+		return nil, errors.New("invalid int map key " + k)
+	}
+	return Int{Value: i}, nil
+}
+
+// EncodeMessage encodes the given object to the protobuf message with the
+// given name. Each key in the object must be the name of a field in the
+// message. The google.protobuf.Timestamp, and google.protobuf.Duration messages
+// are encoded from a time, and a duration respectively.
+func (s *ProtoSchema) EncodeMessage(name string, v Value) ([]byte, error) {
+	msg, err := s.message(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	switch v := v.(type) {
+	case Time:
+		if msg.name == "google.protobuf.Timestamp" {
+			encodeProtoSeconds(&buf, v.Value.Unix(), v.Value.Nanosecond())
+			return buf.Bytes(), nil
+		}
+	case Duration:
+		if msg.name == "google.protobuf.Duration" {
+			encodeProtoSeconds(&buf, int64(v.Value/time.Second), int(v.Value%time.Second))
+			return buf.Bytes(), nil
+		}
+	case Zero:
+		return nil, nil
+	}
+
+	obj, ok := v.(*Object)
+
+	if !ok {
+		return nil, errors.New("cannot encode " + v.valueType().String() + " to " + msg.name)
+	}
+
+	for _, k := range obj.keys() {
+		f, ok := msg.byName[k]
+
+		if !ok {
+			return nil, errors.New("unknown field " + k + " in " + msg.name)
+		}
+
+		val := obj.Pairs[k]
+
+		if _, ok := val.(Zero); ok {
+			continue
+		}
+
+		if err := s.encodeField(&buf, f, val); err != nil {
+			return nil, errors.New("field error " + k + ": " + err.Error())
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeProtoSeconds(buf *bytes.Buffer, sec int64, nsec int) {
+	if sec != 0 {
+		putProtoTag(buf, 1, protoVarint)
+		putVarint(buf, uint64(sec))
+	}
+
+	if nsec != 0 {
+		putProtoTag(buf, 2, protoVarint)
+		putVarint(buf, uint64(int64(nsec)))
+	}
+}
+
+// decodeScalar decodes a single value for the given field from the reader.
+func (s *ProtoSchema) decodeScalar(r *binReader, f *protoField) (Value, error) {
+	switch protoWireType(f.typ) {
+	case protoFixed32:
+		u, err := r.uintLE(4)
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch f.typ {
+		case "float":
+			return Float{Value: float64(math.Float32frombits(uint32(u)))}, nil
+		case "sfixed32":
+			return Int{Value: int64(int32(u))}, nil
+		}
+		return Int{Value: int64(u)}, nil
+	case protoFixed64:
+		u, err := r.uintLE(8)
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch f.typ {
+		case "double":
+			return Float{Value: math.Float64frombits(u)}, nil
+		case "sfixed64":
+			return Int{Value: int64(u)}, nil
+		}
+		return uintValue(u), nil
+	case protoBytes:
+		n, err := r.varint()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if n > uint64(len(r.b)) {
+			return nil, errors.New("invalid protobuf field length")
+		}
+
+		b, err := r.next(int(n))
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch f.typ {
+		case "string":
+			return String{Value: string(b)}, nil
+		case "bytes":
+			return bytesStream(append([]byte{}, b...)), nil
+		}
+		return s.DecodeMessage(f.typeName, b)
+	}
+
+	u, err := r.varint()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch f.typ {
+	case "int32":
+		return Int{Value: int64(int32(u))}, nil
+	case "uint64":
+		return uintValue(u), nil
+	case "sint32", "sint64":
+		return Int{Value: int64(u>>1) ^ -int64(u&1)}, nil
+	case "bool":
+		return Bool{Value: u != 0}, nil
+	case "enum":
+		if name, ok := s.enums[f.typeName].byNum[int32(u)]; ok {
+			return String{Value: name}, nil
+		}
+		return Int{Value: int64(int32(u))}, nil
+	}
+	return Int{Value: int64(u)}, nil
+}
+
+// protoDefault returns the default value for the given field, used for the
+// keys, and values of map entries that are not present.
+func (s *ProtoSchema) protoDefault(f *protoField) Value {
+	switch f.typ {
+	case "string":
+		return String{}
+	case "bytes":
+		return bytesStream(nil)
+	case "bool":
+		return Bool{}
+	case "float", "double":
+		return Float{}
+	case "enum":
+		if name, ok := s.enums[f.typeName].byNum[0]; ok {
+			return String{Value: name}
+		}
+		return Int{}
+	case "message":
+		val, _ := s.DecodeMessage(f.typeName, nil)
+		return val
+	}
+	return Int{}
+}
+
+// skipProto skips over the data of a field with the given wire type.
+func skipProto(r *binReader, wt uint64) error {
+	var err error
+
+	switch wt {
+	case protoVarint:
+		_, err = r.varint()
+	case protoFixed64:
+		_, err = r.next(8)
+	case protoFixed32:
+		_, err = r.next(4)
+	case protoBytes:
+		var n uint64
+
+		if n, err = r.varint(); err == nil {
+			if n > uint64(len(r.b)) {
+				return errors.New("invalid protobuf field length")
+			}
+			_, err = r.next(int(n))
+		}
+	default:
+		return errors.New("unsupported protobuf wire type " + strconv.FormatUint(wt, 10))
+	}
+	return err
+}
+
+// DecodeMessage decodes the given data as the protobuf message with the given
+// name. The fields in the returned object are in the order they are defined
+// in the message, and only the fields present in the data are set. Unknown
+// fields are skipped.
+func (s *ProtoSchema) DecodeMessage(name string, b []byte) (Value, error) {
+	msg, err := s.message(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	vals := make(map[*protoField]Value)
+	repeated := make(map[*protoField][]Value)
+
+	r := &binReader{b: b}
+
+	for r.pos < len(r.b) {
+		tag, err := r.varint()
+
+		if err != nil {
+			return nil, err
+		}
+
+		wt := tag & 7
+
+		f, ok := msg.byNum[int(tag>>3)]
+
+		if !ok {
+			if err := skipProto(r, wt); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !f.repeated {
+			if wt != protoWireType(f.typ) {
+				return nil, errors.New("invalid wire type for field " + msg.name + "." + f.name)
+			}
+
+			val, err := s.decodeScalar(r, f)
+
+			if err != nil {
+				return nil, err
+			}
+
+			// Messages that appear more than once are merged.
+			if obj, ok := val.(*Object); ok {
+				if prev, ok := vals[f].(*Object); ok {
+					for _, k := range obj.Order {
+						if _, ok := prev.Pairs[k]; !ok {
+							prev.Order = append(prev.Order, k)
+						}
+						prev.Pairs[k] = obj.Pairs[k]
+					}
+					continue
+				}
+			}
+
+			vals[f] = val
+			continue
+		}
+
+		items := make([]Value, 0)
+
+		// Packed fields may be encoded either way, so check the wire type
+		// rather than whether the field is packed.
+		if wt == protoBytes && protoWireType(f.typ) != protoBytes {
+			n, err := r.varint()
+
+			if err != nil {
+				return nil, err
+			}
+
+			if n > uint64(len(r.b)) {
+				return nil, errors.New("invalid protobuf field length")
+			}
+
+			p, err := r.next(int(n))
+
+			if err != nil {
+				return nil, err
+			}
+
+			packed := &binReader{b: p}
+
+			for packed.pos < len(packed.b) {
+				val, err := s.decodeScalar(packed, f)
+
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, val)
+			}
+		} else {
+			if wt != protoWireType(f.typ) {
+				return nil, errors.New("invalid wire type for field " + msg.name + "." + f.name)
+			}
+
+			val, err := s.decodeScalar(r, f)
+
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, val)
+		}
+
+		if f.typ == "message" && s.messages[f.typeName].mapEntry {
+			obj, _ := vals[f].(*Object)
+
+			if obj == nil {
+				obj = &Object{
+					Order: make([]string, 0),
+					Pairs: make(map[string]Value),
+				}
+				vals[f] = obj
+			}
+
+			for _, it := range items {
+				entry := it.(*Object)
+
+				key, ok := entry.Pairs["key"]
+
+				if !ok {
+					key = s.protoDefault(s.messages[f.typeName].byNum[1])
+				}
+
+				k, err := binaryKey(key)
+
+				if err != nil {
+					return nil, err
+				}
+
+				if _, ok := obj.Pairs[k]; !ok {
+					obj.Order = append(obj.Order, k)
+				}
+
+				val, ok := entry.Pairs["value"]
+
+				if !ok {
+					val = s.protoDefault(s.messages[f.typeName].byNum[2])
+				}
+				obj.Pairs[k] = val
+			}
+			continue
+		}
+
+		// The items of a repeated field may be split across the message, so
+		// the array is only built once all of them have been read.
+		repeated[f] = append(repeated[f], items...)
+	}
+
+	for f, items := range repeated {
+		vals[f] = CopyArray(items)
+	}
+
+	switch msg.name {
+	case "google.protobuf.Timestamp":
+		sec, _ := vals[msg.byNum[1]].(Int)
+		nsec, _ := vals[msg.byNum[2]].(Int)
+
+		return Time{Value: time.Unix(sec.Value, nsec.Value).UTC()}, nil
+	case "google.protobuf.Duration":
+		sec, _ := vals[msg.byNum[1]].(Int)
+		nsec, _ := vals[msg.byNum[2]].(Int)
+
+		return Duration{Value: time.Duration(sec.Value)*time.Second + time.Duration(nsec.Value)}, nil
+	}
+
+	obj := &Object{
+		Order: make([]string, 0, len(vals)),
+		Pairs: make(map[string]Value),
+	}
+
+	for _, f := range msg.fields {
+		if val, ok := vals[f]; ok {
+			obj.Order = append(obj.Order, f.name)
+			obj.Pairs[f.name] = val
+		}
+	}
+	return obj, nil
+}
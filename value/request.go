@@ -16,6 +16,11 @@ type Request struct {
 	*http.Request
 
 	Transport http.RoundTripper
+
+	// middleware is the list of functions that wrap the Transport when the
+	// request is sent. This allows for commands to modify the request, or
+	// handle the response, at the time of sending.
+	middleware []func(http.RoundTripper) http.RoundTripper
 }
 
 // ToRequest attempts to type assert the given value to a request.
@@ -28,6 +33,28 @@ func ToRequest(v Value) (*Request, error) {
 	return r, nil
 }
 
+// Use adds the given function to the request's middleware. Each function wraps
+// the Transport of the request when it is sent. Middleware added first will be
+// the outermost, so will see the request before any middleware added after it.
+func (r *Request) Use(fn func(http.RoundTripper) http.RoundTripper) {
+	r.middleware = append(r.middleware, fn)
+}
+
+// RoundTripper returns the Transport of the request wrapped in the request's
+// middleware.
+func (r *Request) RoundTripper() http.RoundTripper {
+	rt := r.Transport
+
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		rt = r.middleware[i](rt)
+	}
+	return rt
+}
+
 // Select will return the value of the field with the given name.
 func (r *Request) Select(val Value) (Value, error) {
 	name, err := ToName(val)
@@ -1,6 +1,8 @@
 package value
 
 import (
+	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/andrewpillar/req/syntax"
@@ -10,6 +12,59 @@ type Time struct {
 	Value time.Time
 }
 
+// ToTime attempts to type assert the given value to a time.
+func ToTime(v Value) (Time, error) {
+	t, ok := v.(Time)
+
+	if !ok {
+		return Time{}, typeError(v.valueType(), timeType)
+	}
+	return t, nil
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Value.Format(time.RFC3339Nano))
+}
+
+// Select returns the field of the time with the given name.
+func (t Time) Select(val Value) (Value, error) {
+	name, err := ToName(val)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch name.Value {
+	case "Year":
+		return Int{Value: int64(t.Value.Year())}, nil
+	case "Month":
+		return Int{Value: int64(t.Value.Month())}, nil
+	case "Day":
+		return Int{Value: int64(t.Value.Day())}, nil
+	case "Hour":
+		return Int{Value: int64(t.Value.Hour())}, nil
+	case "Minute":
+		return Int{Value: int64(t.Value.Minute())}, nil
+	case "Second":
+		return Int{Value: int64(t.Value.Second())}, nil
+	case "Weekday":
+		return String{Value: t.Value.Weekday().String()}, nil
+	case "YearDay":
+		return Int{Value: int64(t.Value.YearDay())}, nil
+	case "Unix":
+		return Int{Value: t.Value.Unix()}, nil
+	case "UnixMilli":
+		return Int{Value: t.Value.UnixNano() / int64(time.Millisecond)}, nil
+	case "UTC":
+		return Time{Value: t.Value.UTC()}, nil
+	case "Zone":
+		zone, _ := t.Value.Zone()
+		return String{Value: zone}, nil
+	default:
+		return nil, errors.New("type " + t.valueType().String() + " has no field " + name.Value)
+	}
+}
+
 func (t Time) String() string {
 	return t.Value.Format(time.RFC1123)
 }
@@ -22,6 +77,44 @@ func (t Time) valueType() valueType {
 	return timeType
 }
 
-func (t Time) cmp(op syntax.Op, _ Value) (Value, error) {
-	return nil, opError(op, timeType)
+func (t Time) cmp(op syntax.Op, b Value) (Value, error) {
+	typ := b.valueType()
+
+	if typ != timeType {
+		if typ != zeroType {
+			return nil, compareError(op, t, b)
+		}
+	}
+
+	var u time.Time
+
+	if typ == timeType {
+		u = b.(Time).Value
+	}
+
+	ans := false
+	invert := false
+
+	switch op {
+	case syntax.NeqOp:
+		invert = true
+		fallthrough
+	case syntax.EqOp:
+		ans = t.Value.Equal(u)
+	case syntax.LtOp:
+		ans = t.Value.Before(u)
+	case syntax.LeqOp:
+		ans = !t.Value.After(u)
+	case syntax.GtOp:
+		ans = t.Value.After(u)
+	case syntax.GeqOp:
+		ans = !t.Value.Before(u)
+	default:
+		return nil, opError(op, timeType)
+	}
+
+	if invert {
+		ans = !ans
+	}
+	return Bool{Value: ans}, nil
 }
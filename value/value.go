@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"time"
 
 	"github.com/andrewpillar/req/syntax"
 )
@@ -29,6 +31,8 @@ const (
 	streamType                        // stream
 	nameType                          // name
 	tupleType                         // tuple
+	funcType                          // func
+	iteratorType                      // iterator
 	zeroType                          // zero
 )
 
@@ -37,6 +41,29 @@ func Type(v Value) string {
 	return v.valueType().String()
 }
 
+// scalarText returns the plain text for the given scalar value, as it would be
+// written in a text based format such as XML or CSV. Floats are formatted with
+// as many digits as needed, times are formatted as RFC3339, and the zero value
+// is empty. This returns false if the value is not a scalar.
+func scalarText(v Value) (string, bool) {
+	switch v := v.(type) {
+	case String:
+		return v.Value, true
+	case Float:
+		if v.digits != "" {
+			return v.digits, true
+		}
+		return strconv.FormatFloat(v.Value, 'g', -1, 64), true
+	case Int, Bool, Duration:
+		return v.String(), true
+	case Time:
+		return v.Value.Format(time.RFC3339Nano), true
+	case Zero:
+		return "", true
+	}
+	return "", false
+}
+
 func typeError(typ1, typ2 valueType) error {
 	return fmt.Errorf("cannot use %s as %s", typ1, typ2)
 }
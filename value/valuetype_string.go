@@ -24,12 +24,14 @@ func _() {
 	_ = x[streamType-14]
 	_ = x[nameType-15]
 	_ = x[tupleType-16]
-	_ = x[zeroType-17]
+	_ = x[funcType-17]
+	_ = x[iteratorType-18]
+	_ = x[zeroType-19]
 }
 
-const _valueType_name = "stringintfloatbooltimedurationarrayobjectfileform-datarequestresponsecookiestreamnametuplezero"
+const _valueType_name = "stringintfloatbooltimedurationarrayobjectfileform-datarequestresponsecookiestreamnametuplefunciteratorzero"
 
-var _valueType_index = [...]uint8{0, 6, 9, 14, 18, 22, 30, 35, 41, 45, 54, 61, 69, 75, 81, 85, 90, 94}
+var _valueType_index = [...]uint8{0, 6, 9, 14, 18, 22, 30, 35, 41, 45, 54, 61, 69, 75, 81, 85, 90, 94, 102, 106}
 
 func (i valueType) String() string {
 	i -= 1
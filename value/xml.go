@@ -0,0 +1,360 @@
+package value
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// XMLKind is the kind of node in a parsed XML document.
+type XMLKind uint
+
+const (
+	XMLDocument XMLKind = iota + 1
+	XMLElement
+	XMLAttr
+	XMLText
+)
+
+// XMLNode is a node in a parsed XML document. Names are kept as they appear in
+// the document, including any namespace prefix, such as soap:Body.
+type XMLNode struct {
+	Kind     XMLKind
+	Name     string     // The name of an element or attribute.
+	Data     string     // The data of text, or the value of an attribute.
+	Attrs    []*XMLNode // The attributes of an element.
+	Children []*XMLNode // The child elements and text of a document or element.
+	Parent   *XMLNode
+}
+
+// ParseXML parses the XML in the given reader into a tree of nodes, returning
+// the document node. Comments, processing instructions, and directives are
+// ignored.
+func ParseXML(r io.Reader) (*XMLNode, error) {
+	doc := &XMLNode{
+		Kind: XMLDocument,
+	}
+
+	dec := xml.NewDecoder(r)
+
+	rawName := func(name xml.Name) string {
+		if name.Space == "" {
+			return name.Local
+		}
+		return name.Space + ":" + name.Local
+	}
+
+	curr := doc
+
+	for {
+		tok, err := dec.RawToken()
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		switch v := tok.(type) {
+		case xml.StartElement:
+			n := &XMLNode{
+				Kind:   XMLElement,
+				Name:   rawName(v.Name),
+				Parent: curr,
+			}
+
+			for _, a := range v.Attr {
+				n.Attrs = append(n.Attrs, &XMLNode{
+					Kind:   XMLAttr,
+					Name:   rawName(a.Name),
+					Data:   a.Value,
+					Parent: n,
+				})
+			}
+
+			curr.Children = append(curr.Children, n)
+			curr = n
+		case xml.EndElement:
+			if name := rawName(v.Name); curr.Kind != XMLElement || curr.Name != name {
+				return nil, errors.New("unexpected end element </" + name + ">")
+			}
+			curr = curr.Parent
+		case xml.CharData:
+			if curr == doc {
+				if len(bytes.TrimSpace(v)) > 0 {
+					return nil, errors.New("unexpected text outside of root element")
+				}
+				continue
+			}
+
+			// Merge adjacent text, such as text either side of a comment.
+			if l := len(curr.Children); l > 0 && curr.Children[l-1].Kind == XMLText {
+				curr.Children[l-1].Data += string(v)
+				continue
+			}
+
+			curr.Children = append(curr.Children, &XMLNode{
+				Kind:   XMLText,
+				Data:   string(v),
+				Parent: curr,
+			})
+		}
+	}
+
+	if curr != doc {
+		return nil, errors.New("unexpected end of xml, missing </" + curr.Name + ">")
+	}
+
+	if doc.Root() == nil {
+		return nil, errors.New("missing xml root element")
+	}
+	return doc, nil
+}
+
+// Root returns the root element of the document, or nil if there is none.
+func (n *XMLNode) Root() *XMLNode {
+	for _, child := range n.Children {
+		if child.Kind == XMLElement {
+			return child
+		}
+	}
+	return nil
+}
+
+// Text returns the text content of the node. For elements and documents this
+// is the concatenation of all of the text within them.
+func (n *XMLNode) Text() string {
+	switch n.Kind {
+	case XMLText, XMLAttr:
+		return n.Data
+	}
+
+	var buf strings.Builder
+
+	for _, child := range n.Children {
+		buf.WriteString(child.Text())
+	}
+	return buf.String()
+}
+
+// Value returns the value of the node. Attributes and text are returned as a
+// String. An element without any attributes or child elements is returned as
+// a String of its text, otherwise it is returned as an Object. The object will
+// have the attributes under keys prefixed with @, the child elements under
+// their name, and any text under #text. Repeated child elements are grouped
+// into an Array. A document is returned as an Object with its root element.
+func (n *XMLNode) Value() Value {
+	switch n.Kind {
+	case XMLText, XMLAttr:
+		return String{Value: n.Data}
+	case XMLDocument:
+		obj := &Object{
+			Order: make([]string, 0, 1),
+			Pairs: make(map[string]Value),
+		}
+
+		if root := n.Root(); root != nil {
+			obj.Order = append(obj.Order, root.Name)
+			obj.Pairs[root.Name] = root.Value()
+		}
+		return obj
+	}
+
+	elems := make([]*XMLNode, 0, len(n.Children))
+
+	var text strings.Builder
+
+	for _, child := range n.Children {
+		if child.Kind == XMLElement {
+			elems = append(elems, child)
+			continue
+		}
+		text.WriteString(child.Data)
+	}
+
+	if len(n.Attrs) == 0 && len(elems) == 0 {
+		return String{Value: text.String()}
+	}
+
+	obj := &Object{
+		Order: make([]string, 0, len(n.Attrs)+len(elems)+1),
+		Pairs: make(map[string]Value),
+	}
+
+	for _, a := range n.Attrs {
+		obj.Order = append(obj.Order, "@"+a.Name)
+		obj.Pairs["@"+a.Name] = String{Value: a.Data}
+	}
+
+	groups := make(map[string][]Value)
+
+	for _, el := range elems {
+		if _, ok := groups[el.Name]; !ok {
+			obj.Order = append(obj.Order, el.Name)
+		}
+		groups[el.Name] = append(groups[el.Name], el.Value())
+	}
+
+	for name, vals := range groups {
+		if len(vals) == 1 {
+			obj.Pairs[name] = vals[0]
+			continue
+		}
+		obj.Pairs[name] = CopyArray(vals)
+	}
+
+	if s := strings.TrimSpace(text.String()); s != "" {
+		obj.Order = append(obj.Order, "#text")
+		obj.Pairs["#text"] = String{Value: s}
+	}
+	return obj
+}
+
+// DecodeXML decodes the XML document in the given reader. This returns an
+// Object with the root element as its only key, see XMLNode.Value for how
+// elements are decoded.
+func DecodeXML(r io.Reader) (Value, error) {
+	doc, err := ParseXML(r)
+
+	if err != nil {
+		return nil, err
+	}
+	return doc.Value(), nil
+}
+
+// xmlText returns the text for the given value when encoded as either the text
+// of an element, or the value of an attribute.
+func xmlText(v Value) (string, error) {
+	s, ok := scalarText(v)
+
+	if !ok {
+		return "", errors.New("cannot encode " + v.valueType().String() + " to xml text")
+	}
+	return s, nil
+}
+
+func encodeXml(enc *xml.Encoder, name string, v Value) error {
+	if name == "" || strings.ContainsAny(name[:1], "@#") {
+		return errors.New("invalid xml element name " + name)
+	}
+
+	start := xml.StartElement{
+		Name: xml.Name{Local: name},
+	}
+
+	switch v := v.(type) {
+	case *Array:
+		for _, it := range v.Items {
+			if _, ok := it.(*Array); ok {
+				return errors.New("cannot encode nested array to xml element <" + name + ">")
+			}
+
+			if err := encodeXml(enc, name, it); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *Object:
+		keys := v.keys()
+
+		for _, k := range keys {
+			if !strings.HasPrefix(k, "@") {
+				continue
+			}
+
+			s, err := xmlText(v.Pairs[k])
+
+			if err != nil {
+				return err
+			}
+
+			start.Attr = append(start.Attr, xml.Attr{
+				Name:  xml.Name{Local: k[1:]},
+				Value: s,
+			})
+		}
+
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if strings.HasPrefix(k, "@") {
+				continue
+			}
+
+			if k == "#text" {
+				s, err := xmlText(v.Pairs[k])
+
+				if err != nil {
+					return err
+				}
+
+				if err := enc.EncodeToken(xml.CharData(s)); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := encodeXml(enc, k, v.Pairs[k]); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	}
+
+	s, err := xmlText(v)
+
+	if err != nil {
+		return err
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	if s != "" {
+		if err := enc.EncodeToken(xml.CharData(s)); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// EncodeXML encodes the given object to XML. The object must have a single
+// key, which is used as the root element. Values are encoded in the same shape
+// they are decoded in by DecodeXML.
+func EncodeXML(v Value) ([]byte, error) {
+	obj, err := ToObject(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if l := len(obj.Pairs); l != 1 {
+		return nil, errors.New("cannot encode object with " + strconv.Itoa(l) + " keys to xml, expected a single root element")
+	}
+
+	root := obj.keys()[0]
+
+	if _, ok := obj.Pairs[root].(*Array); ok {
+		return nil, errors.New("cannot encode array as xml root element")
+	}
+
+	var buf bytes.Buffer
+
+	enc := xml.NewEncoder(&buf)
+
+	if err := encodeXml(enc, root, obj.Pairs[root]); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
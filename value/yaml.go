@@ -0,0 +1,292 @@
+package value
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// decodeYaml decodes the given YAML node. Mappings are decoded to objects with
+// their keys in the order they appear in the source, sequences to arrays, and
+// scalars to either a String, Int, Float, Bool, or Zero depending on their
+// tag.
+func decodeYaml(n *yaml.Node) (Value, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return Zero{}, nil
+		}
+		return decodeYaml(n.Content[0])
+	case yaml.AliasNode:
+		return decodeYaml(n.Alias)
+	case yaml.SequenceNode:
+		arr := &Array{
+			set:   make(map[uint32]struct{}),
+			Items: make([]Value, 0, len(n.Content)),
+		}
+
+		for _, child := range n.Content {
+			val, err := decodeYaml(child)
+
+			if err != nil {
+				return nil, err
+			}
+			arr.Items = append(arr.Items, val)
+		}
+
+		arr.hashItems()
+
+		return arr, nil
+	case yaml.MappingNode:
+		obj := &Object{
+			Order: make([]string, 0, len(n.Content)/2),
+			Pairs: make(map[string]Value),
+		}
+
+		if err := decodeYamlMapping(obj, n); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	case yaml.ScalarNode:
+		return decodeYamlScalar(n)
+	}
+	return nil, errors.New("unexpected yaml node at line " + strconv.Itoa(n.Line))
+}
+
+// decodeYamlMapping decodes the key-value pairs in the given mapping node into
+// the given object. Merge keys (<<) are handled by decoding the merged
+// mappings into the object, without overwriting any keys that are set
+// explicitly.
+func decodeYamlMapping(obj *Object, n *yaml.Node) error {
+	merges := make([]*yaml.Node, 0)
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, val := n.Content[i], n.Content[i+1]
+
+		if key.Tag == "!!merge" {
+			merges = append(merges, val)
+			continue
+		}
+
+		v, err := decodeYaml(val)
+
+		if err != nil {
+			return err
+		}
+
+		if _, ok := obj.Pairs[key.Value]; !ok {
+			obj.Order = append(obj.Order, key.Value)
+		}
+		obj.Pairs[key.Value] = v
+	}
+
+	for _, m := range merges {
+		if m.Kind == yaml.AliasNode {
+			m = m.Alias
+		}
+
+		nodes := []*yaml.Node{m}
+
+		if m.Kind == yaml.SequenceNode {
+			nodes = m.Content
+		}
+
+		for _, node := range nodes {
+			if node.Kind == yaml.AliasNode {
+				node = node.Alias
+			}
+
+			if node.Kind != yaml.MappingNode {
+				return errors.New("cannot merge non-mapping at line " + strconv.Itoa(node.Line))
+			}
+
+			merged := &Object{
+				Order: make([]string, 0),
+				Pairs: make(map[string]Value),
+			}
+
+			if err := decodeYamlMapping(merged, node); err != nil {
+				return err
+			}
+
+			for _, k := range merged.Order {
+				if _, ok := obj.Pairs[k]; ok {
+					continue
+				}
+				obj.Order = append(obj.Order, k)
+				obj.Pairs[k] = merged.Pairs[k]
+			}
+		}
+	}
+	return nil
+}
+
+func decodeYamlScalar(n *yaml.Node) (Value, error) {
+	switch n.ShortTag() {
+	case "!!null":
+		return Zero{}, nil
+	case "!!bool":
+		var b bool
+
+		if err := n.Decode(&b); err != nil {
+			return nil, err
+		}
+		return Bool{Value: b}, nil
+	case "!!int":
+		var i int64
+
+		if err := n.Decode(&i); err != nil {
+			// Keep the digits of integers too large for an Int.
+			var f float64
+
+			if err := n.Decode(&f); err != nil {
+				return nil, err
+			}
+			return Float{Value: f, digits: n.Value}, nil
+		}
+		return Int{Value: i}, nil
+	case "!!float":
+		var f float64
+
+		if err := n.Decode(&f); err != nil {
+			return nil, err
+		}
+		return Float{Value: f}, nil
+	}
+	return String{Value: n.Value}, nil
+}
+
+// DecodeYAML decodes all of the YAML documents in the given reader. If there
+// is only a single document, then the value of that document is returned,
+// otherwise an array of the value of each document is returned.
+func DecodeYAML(r io.Reader) (Value, error) {
+	dec := yaml.NewDecoder(r)
+
+	docs := make([]Value, 0, 1)
+
+	for {
+		var n yaml.Node
+
+		if err := dec.Decode(&n); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		val, err := decodeYaml(&n)
+
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, val)
+	}
+
+	switch len(docs) {
+	case 0:
+		return Zero{}, nil
+	case 1:
+		return docs[0], nil
+	}
+	return CopyArray(docs), nil
+}
+
+func encodeYaml(v Value) (*yaml.Node, error) {
+	switch v := v.(type) {
+	case *Object:
+		n := &yaml.Node{
+			Kind: yaml.MappingNode,
+		}
+
+		for _, k := range v.keys() {
+			val, err := encodeYaml(v.Pairs[k])
+
+			if err != nil {
+				return nil, err
+			}
+
+			n.Content = append(n.Content, &yaml.Node{
+				Kind:  yaml.ScalarNode,
+				Tag:   "!!str",
+				Value: k,
+			}, val)
+		}
+		return n, nil
+	case *Array:
+		n := &yaml.Node{
+			Kind: yaml.SequenceNode,
+		}
+
+		for _, it := range v.Items {
+			val, err := encodeYaml(it)
+
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, val)
+		}
+		return n, nil
+	case String:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v.Value}, nil
+	case Int:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: v.String()}, nil
+	case Float:
+		if v.whole() {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: v.digits}, nil
+		}
+
+		if v.digits != "" {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: v.digits}, nil
+		}
+
+		s := strconv.FormatFloat(v.Value, 'g', -1, 64)
+
+		switch {
+		case math.IsInf(v.Value, 1):
+			s = ".inf"
+		case math.IsInf(v.Value, -1):
+			s = "-.inf"
+		case math.IsNaN(v.Value):
+			s = ".nan"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: s}, nil
+	case Bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v.Value)}, nil
+	case Time:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: v.Value.Format(time.RFC3339Nano)}, nil
+	case Duration:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v.Value.String()}, nil
+	case Zero:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+	return nil, errors.New("cannot encode " + v.valueType().String() + " to yaml")
+}
+
+// EncodeYAML encodes the given value to YAML. The keys of objects are encoded
+// in the order in which they were defined.
+func EncodeYAML(v Value) ([]byte, error) {
+	n, err := encodeYaml(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(n); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}